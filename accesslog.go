@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Access log formats accepted by access_log_format.
+const (
+	accessLogCommon   = "common"
+	accessLogCombined = "combined"
+	accessLogJSON     = "json"
+)
+
+// clfTimeFormat is the timestamp layout of the Common Log Format.
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// statusWriter records the status and number of body bytes written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader records the status before passing it through.
+func (s *statusWriter) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes before passing them through.
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// FlushError passes flushes through so streamed responses are not buffered. It returns
+// http.ErrNotSupported if the wrapped writer can't flush.
+func (s *statusWriter) FlushError() error {
+	return http.NewResponseController(s.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (s *statusWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// accessLogEntry describes a completed request.
+type accessLogEntry struct {
+	RemoteAddr string    `json:"remote_addr"`
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	Referer    string    `json:"referer"`
+	UserAgent  string    `json:"user_agent"`
+}
+
+// accessLogOutput is where the access log is written, replaceable in tests.
+var accessLogOutput io.Writer = os.Stdout
+
+// accessLogMiddleware writes one line per request to out in the given format. Lines
+// from concurrent requests are written one at a time. Requests whose connection is reset
+// are logged with status 0.
+func accessLogMiddleware(format string, out io.Writer) mux.MiddlewareFunc {
+	var mu sync.Mutex
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			logRequest := func(status int) {
+				host, _, err := net.SplitHostPort(r.RemoteAddr)
+				if err != nil {
+					host = r.RemoteAddr
+				}
+				entry := accessLogEntry{
+					RemoteAddr: host,
+					Time:       start,
+					Method:     r.Method,
+					URI:        r.URL.RequestURI(),
+					Proto:      r.Proto,
+					Status:     status,
+					Bytes:      sw.bytes,
+					Referer:    r.Referer(),
+					UserAgent:  r.UserAgent(),
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if _, err := io.WriteString(out, formatAccessLog(format, entry)+"\n"); err != nil {
+					log.Printf("Error writing access log: %v", err)
+				}
+			}
+
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					// net/http drops the connection for this panic, so no status was sent.
+					if recovered == http.ErrAbortHandler {
+						logRequest(0)
+					}
+					panic(recovered)
+				}
+			}()
+			next.ServeHTTP(sw, r)
+			if sw.status == 0 {
+				sw.status = http.StatusOK
+			}
+			logRequest(sw.status)
+		})
+	}
+}
+
+// formatAccessLog renders an entry as a Common, Combined, or JSON log line.
+func formatAccessLog(format string, entry accessLogEntry) string {
+	if format == accessLogJSON {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			log.Printf("Error encoding access log: %v", err)
+		}
+		return string(line)
+	}
+
+	size := "-"
+	if entry.Bytes > 0 {
+		size = strconv.Itoa(entry.Bytes)
+	}
+	line := fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s",
+		entry.RemoteAddr, entry.Time.Format(clfTimeFormat), entry.Method, entry.URI, entry.Proto, entry.Status, size)
+	if format == accessLogCombined {
+		line += fmt.Sprintf(" %q %q", orDash(entry.Referer), orDash(entry.UserAgent))
+	}
+	return line
+}
+
+// orDash returns "-" for empty log fields, as the Common Log Format does.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{accessLogCommon, `^192\.0\.2\.1 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /v1/test\?a=1 HTTP/1\.1" 201 5$`},
+		{accessLogCombined, `^192\.0\.2\.1 - - \[[^\]]+\] "POST /v1/test\?a=1 HTTP/1\.1" 201 5 "https://example\.com/" "curl/8\.0"$`},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		req := httptest.NewRequest(http.MethodPost, "/v1/test?a=1", nil)
+		req.Header.Set("Referer", "https://example.com/")
+		req.Header.Set("User-Agent", "curl/8.0")
+		accessLogMiddleware(tt.format, &out)(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+		if line := strings.TrimSuffix(out.String(), "\n"); !regexp.MustCompile(tt.want).MatchString(line) {
+			t.Errorf("%s: unexpected log line %q", tt.format, line)
+		}
+	}
+}
+
+func TestFormatAccessLog(t *testing.T) {
+	entry := accessLogEntry{
+		RemoteAddr: "192.0.2.1",
+		Time:       time.Date(2024, time.March, 5, 14, 7, 9, 0, time.UTC),
+		Method:     "GET",
+		URI:        "/v1/test",
+		Proto:      "HTTP/1.1",
+		Status:     204,
+	}
+	want := `192.0.2.1 - - [05/Mar/2024:14:07:09 +0000] "GET /v1/test HTTP/1.1" 204 -`
+	if got := formatAccessLog(accessLogCommon, entry); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := formatAccessLog(accessLogCombined, entry); got != want+` "-" "-"` {
+		t.Errorf("Expected empty referer and user agent as dashes, got %q", got)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(formatAccessLog(accessLogJSON, entry)), &decoded); err != nil {
+		t.Fatalf("Expected a JSON log line, got error: %v", err)
+	}
+	if decoded["status"] != float64(204) || decoded["uri"] != "/v1/test" {
+		t.Errorf("Unexpected JSON log line: %v", decoded)
+	}
+}
+
+// TestAccessLogRejectedRequest verifies a request rejected by max_concurrent is still logged.
+func TestAccessLogRejectedRequest(t *testing.T) {
+	var out bytes.Buffer
+	accessLogOutput = &out
+	defer func() { accessLogOutput = os.Stdout }()
+
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 300, High: 300}
+	config.MaxConcurrent = 1
+	config.AccessLogFormat = accessLogCommon
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serve(router, http.MethodGet, "/v1/test")
+	}()
+
+	// Let the first request reach the handler before sending one more.
+	time.Sleep(100 * time.Millisecond)
+	if w := serve(router, http.MethodGet, "/v1/test"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 over the limit, got %d", w.Code)
+	}
+	wg.Wait()
+
+	if !strings.Contains(out.String(), `"GET /v1/test HTTP/1.1" 503 `) {
+		t.Errorf("Expected the rejected request to be logged, got %q", out.String())
+	}
+}
+
+// TestAccessLogResetConnection verifies a request whose connection is reset is logged
+// with status 0 and the abort still reaches net/http.
+func TestAccessLogResetConnection(t *testing.T) {
+	var out bytes.Buffer
+	accessLogOutput = &out
+	defer func() { accessLogOutput = os.Stdout }()
+
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.AccessLogFormat = accessLogCommon
+	config.ErrorResponse.Code = http.StatusServiceUnavailable
+	config.ErrorResponse.Frequency = 1.0
+	config.ErrorResponse.ResetProbability = map[int]float64{http.StatusServiceUnavailable: 1.0}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != http.ErrAbortHandler {
+				t.Errorf("Expected the handler to abort, recovered %v", recovered)
+			}
+		}()
+		serve(router, http.MethodGet, "/v1/test")
+	}()
+
+	if !strings.Contains(out.String(), `"GET /v1/test HTTP/1.1" 0 -`) {
+		t.Errorf("Expected the reset request to be logged with status 0, got %q", out.String())
+	}
+}
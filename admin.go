@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// registerAdminHandlers sets up the /admin and /metrics endpoints used to inspect and
+// control the mock while it runs.
+func registerAdminHandlers(router *mux.Router, config *Config) {
+	router.HandleFunc("/admin/reset", func(w http.ResponseWriter, r *http.Request) {
+		handleAdminReset(w, r, config)
+	}).Methods(http.MethodPost)
+	router.HandleFunc("/admin/maintenance/{state:on|off}", func(w http.ResponseWriter, r *http.Request) {
+		handleAdminMaintenance(w, r, config)
+	}).Methods(http.MethodPost)
+	router.HandleFunc("/admin/counts", func(w http.ResponseWriter, r *http.Request) {
+		normalResponse(w, getRuntimeState(config).requestCounts.snapshot(), config)
+	}).Methods(http.MethodGet)
+	router.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		handleMetrics(w, config)
+	}).Methods(http.MethodGet)
+}
+
+// handleAdminReset zeroes the error simulator, request counters, and the counters behind
+// fail_first, every_nth_error, and request_count_lt. A ?path= parameter limits the reset
+// to that path.
+func handleAdminReset(w http.ResponseWriter, r *http.Request, config *Config) {
+	path := strings.TrimRight(r.URL.Query().Get("path"), "/")
+	state := getRuntimeState(config)
+	count := state.resetSimulators(path)
+	if path != "" && count == 0 {
+		sendJSONError(w, http.StatusNotFound, "No simulator for path "+path, config)
+		return
+	}
+	state.requestCounts.reset(path)
+	state.responseCounts.resetPath(path)
+	state.attemptCounts.resetPath(path)
+	state.nthErrorCounts.resetPath(path)
+	log.Printf("Reset %d error simulators", count)
+	normalResponse(w, map[string]int{"reset": count}, config)
+}
+
+// handleAdminMaintenance switches maintenance mode on or off. Scheduled windows apply
+// regardless of the toggle.
+func handleAdminMaintenance(w http.ResponseWriter, r *http.Request, config *Config) {
+	on := mux.Vars(r)["state"] == "on"
+	getRuntimeState(config).maintenance.Store(on)
+	log.Printf("Maintenance mode switched %s", mux.Vars(r)["state"])
+	normalResponse(w, map[string]bool{"maintenance": on}, config)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// createAdminTestRouter returns a config whose paths always error and a router serving it.
+func createAdminTestRouter() (*Config, http.Handler) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.ErrorResponse.Frequency = 1.0
+	spec := &APISpec{Paths: map[string]map[string]interface{}{
+		"/a": {"get": map[string]interface{}{}},
+		"/b": {"get": map[string]interface{}{}},
+	}}
+	return config, setupRouter(config, spec)
+}
+
+// serve sends a request through the router and returns the recorder.
+func serve(router http.Handler, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+// TestAdminReset verifies resetting zeroes the error rate of every simulator.
+func TestAdminReset(t *testing.T) {
+	config, router := createAdminTestRouter()
+	serve(router, http.MethodGet, "/v1/a")
+	serve(router, http.MethodGet, "/v1/b")
+
+	simulators := getRuntimeState(config).simulators
+	if simulators["/v1/a"].GetCurrentErrorRate() == 0 {
+		t.Fatal("Expected errors before the reset")
+	}
+
+	if w := serve(router, http.MethodPost, "/admin/reset"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 from reset, got %d", w.Code)
+	}
+	for key, simulator := range simulators {
+		if rate := simulator.GetCurrentErrorRate(); rate != 0 {
+			t.Errorf("Expected %s error rate 0 after reset, got %v", key, rate)
+		}
+	}
+}
+
+// TestAdminResetPath verifies a path-limited reset leaves other simulators alone.
+func TestAdminResetPath(t *testing.T) {
+	config, router := createAdminTestRouter()
+	serve(router, http.MethodGet, "/v1/a")
+	serve(router, http.MethodGet, "/v1/b")
+
+	if w := serve(router, http.MethodPost, "/admin/reset?path=/v1/a"); w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 from reset, got %d", w.Code)
+	}
+	simulators := getRuntimeState(config).simulators
+	if rate := simulators["/v1/a"].GetCurrentErrorRate(); rate != 0 {
+		t.Errorf("Expected /v1/a error rate 0 after reset, got %v", rate)
+	}
+	if rate := simulators["/v1/b"].GetCurrentErrorRate(); rate == 0 {
+		t.Error("Expected /v1/b to keep its error rate")
+	}
+
+	if w := serve(router, http.MethodPost, "/admin/reset?path=/v1/unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown path, got %d", w.Code)
+	}
+}
+
+// TestAdminResetCounters verifies a reset restarts the fail_first, every_nth_error, and
+// request_count_lt sequences.
+func TestAdminResetCounters(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses["/v1/c"] = `{"status":"ready"}`
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/a": {FailFirst: 1},
+		"/v1/b": {EveryNthError: 2},
+		"/v1/c": {Rules: []ResponseRule{{
+			When: RuleConditions{RequestCountLT: 1},
+			Body: `{"status":"initializing"}`,
+		}}},
+	}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{
+		"/a": {"get": map[string]interface{}{}},
+		"/b": {"get": map[string]interface{}{}},
+		"/c": {"get": map[string]interface{}{}},
+	}}
+	router := setupRouter(config, spec)
+
+	sequence := func() []interface{} {
+		var got []interface{}
+		for _, path := range []string{"/v1/a", "/v1/b", "/v1/c"} {
+			for i := 0; i < 3; i++ {
+				w := serve(router, http.MethodGet, path)
+				var body map[string]interface{}
+				json.Unmarshal(w.Body.Bytes(), &body)
+				got = append(got, w.Code, body["status"])
+			}
+		}
+		return got
+	}
+	errorCode := config.ErrorResponse.Code
+	want := []interface{}{
+		errorCode, nil, http.StatusOK, nil, http.StatusOK, nil,
+		http.StatusOK, nil, errorCode, nil, http.StatusOK, nil,
+		http.StatusOK, "initializing", http.StatusOK, "ready", http.StatusOK, "ready",
+	}
+	if got := sequence(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v before the reset, got %v", want, got)
+	}
+	serve(router, http.MethodPost, "/admin/reset")
+	if got := sequence(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v after the reset, got %v", want, got)
+	}
+}
+
+// TestAdminMaintenance verifies toggling maintenance makes every endpoint return 503.
+func TestAdminMaintenance(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{
+		"/a": {"get": map[string]interface{}{}},
+		"/b": {"get": map[string]interface{}{}},
+	}}
+	router := setupRouter(config, spec)
+
+	serve(router, http.MethodPost, "/admin/maintenance/on")
+	for _, path := range []string{"/v1/a", "/v1/b"} {
+		if w := serve(router, http.MethodGet, path); w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected status 503 during maintenance, got %d", path, w.Code)
+		}
+	}
+
+	serve(router, http.MethodPost, "/admin/maintenance/off")
+	for _, path := range []string{"/v1/a", "/v1/b"} {
+		if w := serve(router, http.MethodGet, path); w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200 after maintenance, got %d", path, w.Code)
+		}
+	}
+}
+
+// TestAdminCounts verifies the per-path request counts reflect calls and reset with the simulators.
+func TestAdminCounts(t *testing.T) {
+	_, router := createAdminTestRouter()
+	serve(router, http.MethodGet, "/v1/a")
+	serve(router, http.MethodGet, "/v1/a")
+	serve(router, http.MethodGet, "/v1/b")
+
+	counts := func() map[string]uint64 {
+		w := serve(router, http.MethodGet, "/admin/counts")
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 from counts, got %d", w.Code)
+		}
+		var counts map[string]uint64
+		if err := json.Unmarshal(w.Body.Bytes(), &counts); err != nil {
+			t.Fatalf("Failed to decode counts: %v", err)
+		}
+		return counts
+	}
+	if got := counts(); got["/v1/a"] != 2 || got["/v1/b"] != 1 {
+		t.Errorf("Expected /v1/a:2 and /v1/b:1, got %v", got)
+	}
+
+	serve(router, http.MethodPost, "/admin/reset?path=/v1/a")
+	if got := counts(); got["/v1/a"] != 0 || got["/v1/b"] != 1 {
+		t.Errorf("Expected only /v1/a reset, got %v", got)
+	}
+	serve(router, http.MethodPost, "/admin/reset")
+	if got := counts(); got["/v1/b"] != 0 {
+		t.Errorf("Expected every count reset, got %v", got)
+	}
+}
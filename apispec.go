@@ -4,7 +4,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v2"
@@ -12,10 +13,33 @@ import (
 
 // APISpec is a minimal structure to parse the “paths” from an API YAML.
 type APISpec struct {
-	Paths map[string]map[string]interface{} `yaml:"paths"`
+	Paths      map[string]map[string]interface{} `yaml:"paths"`
+	Servers    []APIServer                       `yaml:"servers"`
+	Components struct {
+		Parameters map[string]interface{} `yaml:"parameters"`
+	} `yaml:"components"`
 }
 
-// loadAPISpec loads (via HTTP GET or file read) and parses the API YAML.
+// APIServer is an entry of the OpenAPI “servers” block.
+type APIServer struct {
+	URL string `yaml:"url"`
+}
+
+// basePath returns the path component of the first server URL without surrounding
+// slashes, e.g. "api/v2" for "https://example.com/api/v2/". It returns "" if the spec
+// declares no servers or the URL cannot be parsed.
+func (s *APISpec) basePath() string {
+	if len(s.Servers) == 0 {
+		return ""
+	}
+	u, err := url.Parse(s.Servers[0].URL)
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(u.Path, "/")
+}
+
+// loadAPISpec loads (via HTTP GET, file read, or stdin for "-") and parses the API YAML.
 func loadAPISpec(specURL string) (*APISpec, error) {
 	var data []byte
 	var err error
@@ -30,7 +54,7 @@ func loadAPISpec(specURL string) (*APISpec, error) {
 			return nil, fmt.Errorf("error reading API spec HTTP response: %v", err)
 		}
 	} else {
-		data, err = os.ReadFile(specURL)
+		data, err = readFileOrStdin(specURL)
 		if err != nil {
 			return nil, fmt.Errorf("error reading API spec file: %v", err)
 		}
@@ -39,5 +63,232 @@ func loadAPISpec(specURL string) (*APISpec, error) {
 	if err := yaml.Unmarshal(data, &spec); err != nil {
 		return nil, fmt.Errorf("error parsing API spec: %v", err)
 	}
+	if err := spec.resolveParameters(); err != nil {
+		return nil, fmt.Errorf("error parsing API spec: %v", err)
+	}
 	return &spec, nil
 }
+
+// resolveParameters moves the parameters declared on each path item into its operations,
+// where an operation's own parameter with the same name and location takes precedence.
+// $ref parameters are replaced with the components.parameters entry they point to.
+func (s *APISpec) resolveParameters() error {
+	for path, item := range s.Paths {
+		shared, err := s.parameterList(item["parameters"])
+		if err != nil {
+			return fmt.Errorf("parameters of %s: %v", path, err)
+		}
+		delete(item, "parameters")
+		for method, operation := range item {
+			fields, ok := operation.(map[interface{}]interface{})
+			if !ok {
+				continue
+			}
+			own, err := s.parameterList(fields["parameters"])
+			if err != nil {
+				return fmt.Errorf("parameters of %s %s: %v", strings.ToUpper(method), path, err)
+			}
+			declared := make(map[string]bool, len(own))
+			for _, parameter := range own {
+				declared[parameterKey(parameter)] = true
+			}
+			for _, parameter := range shared {
+				if !declared[parameterKey(parameter)] {
+					own = append(own, parameter)
+				}
+			}
+			if len(own) == 0 {
+				continue
+			}
+			resolved := make(map[interface{}]interface{}, len(fields))
+			for key, value := range fields {
+				resolved[key] = value
+			}
+			resolved["parameters"] = own
+			item[method] = resolved
+		}
+	}
+	return nil
+}
+
+// parameterList returns the entries of a parameters block with $refs resolved.
+func (s *APISpec) parameterList(block interface{}) ([]interface{}, error) {
+	entries, _ := block.([]interface{})
+	parameters := make([]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		if fields, ok := entry.(map[interface{}]interface{}); ok {
+			if ref, ok := fields["$ref"].(string); ok {
+				name, found := strings.CutPrefix(ref, "#/components/parameters/")
+				target, defined := s.Components.Parameters[name]
+				if !found || !defined {
+					return nil, fmt.Errorf("unknown parameter $ref %q", ref)
+				}
+				entry = target
+			}
+		}
+		parameters = append(parameters, entry)
+	}
+	return parameters, nil
+}
+
+// parameterKey identifies a parameter by its location and name.
+func parameterKey(parameter interface{}) string {
+	fields, _ := parameter.(map[interface{}]interface{})
+	return fmt.Sprintf("%v %v", fields["in"], fields["name"])
+}
+
+// loadAPISpecs loads every spec and merges their paths into one spec. Servers are kept
+// in order, so the first spec's server sets the base path. Two specs defining the same
+// method for a path is an error.
+func loadAPISpecs(sources []string) (*APISpec, error) {
+	merged := &APISpec{Paths: make(map[string]map[string]interface{})}
+	definedIn := make(map[string]string)
+	for _, source := range sources {
+		spec, err := loadAPISpec(source)
+		if err != nil {
+			return nil, err
+		}
+		merged.Servers = append(merged.Servers, spec.Servers...)
+		// Sorted, so a conflict is reported the same way every time.
+		paths := make([]string, 0, len(spec.Paths))
+		for path := range spec.Paths {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			methods := spec.Paths[path]
+			if merged.Paths[path] == nil {
+				merged.Paths[path] = make(map[string]interface{}, len(methods))
+			}
+			names := make([]string, 0, len(methods))
+			for method := range methods {
+				names = append(names, method)
+			}
+			sort.Strings(names)
+			for _, method := range names {
+				operation := methods[method]
+				key := strings.ToLower(method) + " " + path
+				if previous, ok := definedIn[key]; ok {
+					return nil, fmt.Errorf("conflicting API specs: %s %s is defined in both %s and %s", strings.ToUpper(method), path, previous, source)
+				}
+				definedIn[key] = source
+				merged.Paths[path][method] = operation
+			}
+		}
+	}
+	return merged, nil
+}
+
+// MockHints are mock behavior hints declared on an operation under the x-mock extension.
+type MockHints struct {
+	ErrorFrequency *float64       `yaml:"error_frequency"`
+	Latency        *LatencyConfig `yaml:"latency"`
+}
+
+// parseMockHints extracts the x-mock extension from an operation of the spec.
+// Operations without the extension yield empty hints.
+func parseMockHints(operation interface{}) (MockHints, error) {
+	var hints MockHints
+	fields, ok := operation.(map[interface{}]interface{})
+	if !ok {
+		return hints, nil
+	}
+	extension, ok := fields["x-mock"]
+	if !ok {
+		return hints, nil
+	}
+	data, err := yaml.Marshal(extension)
+	if err != nil {
+		return hints, fmt.Errorf("error reading x-mock extension: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &hints); err != nil {
+		return hints, fmt.Errorf("error parsing x-mock extension: %v", err)
+	}
+	return hints, nil
+}
+
+// specOperation is the part of an operation that describes its parameters and responses.
+// Its parameters include those of the path item once the spec is loaded.
+type specOperation struct {
+	Parameters []struct {
+		Name     string `yaml:"name"`
+		In       string `yaml:"in"`
+		Required bool   `yaml:"required"`
+		Schema   struct {
+			Type string `yaml:"type"`
+		} `yaml:"schema"`
+	} `yaml:"parameters"`
+	Responses map[string]struct {
+		Content map[string]struct {
+			Example interface{} `yaml:"example"`
+		} `yaml:"content"`
+	} `yaml:"responses"`
+}
+
+// decodeOperation converts an operation of the spec into a specOperation.
+func decodeOperation(operation interface{}) (specOperation, error) {
+	var op specOperation
+	data, err := yaml.Marshal(operation)
+	if err != nil {
+		return op, fmt.Errorf("error reading operation: %v", err)
+	}
+	if err := yaml.Unmarshal(data, &op); err != nil {
+		return op, fmt.Errorf("error parsing operation: %v", err)
+	}
+	return op, nil
+}
+
+// parseRequiredQueryParams returns the names of the query parameters the operation
+// declares as required.
+func parseRequiredQueryParams(operation interface{}) ([]string, error) {
+	op, err := decodeOperation(operation)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, parameter := range op.Parameters {
+		if parameter.In == "query" && parameter.Required {
+			names = append(names, parameter.Name)
+		}
+	}
+	return names, nil
+}
+
+// parsePathParamTypes returns the schema types the operation declares for its path
+// parameters, including those of its path item, keyed by parameter name. Parameters
+// without a type are left out.
+func parsePathParamTypes(operation interface{}) (map[string]string, error) {
+	op, err := decodeOperation(operation)
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]string)
+	for _, parameter := range op.Parameters {
+		if parameter.In == "path" && parameter.Schema.Type != "" {
+			types[parameter.Name] = parameter.Schema.Type
+		}
+	}
+	return types, nil
+}
+
+// parseResponseExample returns the application/json example of the operation's lowest
+// 2xx response, if it declares one.
+func parseResponseExample(operation interface{}) (interface{}, bool, error) {
+	op, err := decodeOperation(operation)
+	if err != nil {
+		return nil, false, err
+	}
+	codes := make([]string, 0, len(op.Responses))
+	for code := range op.Responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		if media, ok := op.Responses[code].Content["application/json"]; ok && media.Example != nil {
+			return media.Example, true, nil
+		}
+	}
+	return nil, false, nil
+}
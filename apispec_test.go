@@ -1,9 +1,13 @@
 package main
 
 import (
+	"io"
+	"net/http"
 	"os"
 	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v2"
 )
 
 const validAPISpec = `
@@ -73,3 +77,236 @@ func TestLoadAPISpecHTTPFailure(t *testing.T) {
 		t.Fatalf("Expected HTTP fetch error, got: %v", err)
 	}
 }
+
+func TestAPISpecBasePath(t *testing.T) {
+	tests := []struct {
+		servers []APIServer
+		want    string
+	}{
+		{nil, ""},
+		{[]APIServer{{URL: "https://example.com/api/v2/"}}, "api/v2"},
+		{[]APIServer{{URL: "/api/v3"}, {URL: "https://example.com/other"}}, "api/v3"},
+		{[]APIServer{{URL: "https://example.com"}}, ""},
+	}
+	for _, tt := range tests {
+		spec := &APISpec{Servers: tt.servers}
+		if got := spec.basePath(); got != tt.want {
+			t.Errorf("basePath() for %+v = %q, want %q", tt.servers, got, tt.want)
+		}
+	}
+}
+
+func TestParseResponseExample(t *testing.T) {
+	operation := map[interface{}]interface{}{
+		"responses": map[interface{}]interface{}{
+			"404": map[interface{}]interface{}{"description": "missing"},
+			"201": map[interface{}]interface{}{
+				"content": map[interface{}]interface{}{
+					"application/json": map[interface{}]interface{}{
+						"example": map[interface{}]interface{}{"id": 1},
+					},
+				},
+			},
+		},
+	}
+	example, ok, err := parseResponseExample(operation)
+	if err != nil || !ok {
+		t.Fatalf("Expected an example, got ok=%v err=%v", ok, err)
+	}
+	if got := example.(map[interface{}]interface{})["id"]; got != 1 {
+		t.Errorf("Expected the 201 example, got %v", example)
+	}
+
+	if _, ok, _ := parseResponseExample(map[string]interface{}{}); ok {
+		t.Error("Expected no example for an operation without responses")
+	}
+}
+
+func TestLoadAPISpecStdin(t *testing.T) {
+	defer func(original io.Reader) { stdin = original }(stdin)
+	stdin = strings.NewReader(validAPISpec)
+
+	spec, err := loadAPISpec("-")
+	if err != nil {
+		t.Fatalf("Expected spec to load from stdin, got error: %v", err)
+	}
+	if _, ok := spec.Paths["/test"]; !ok {
+		t.Errorf("Expected /test path from stdin, got %v", spec.Paths)
+	}
+}
+
+func TestValidateRequestsRequiredQuery(t *testing.T) {
+	var spec APISpec
+	err := yaml.Unmarshal([]byte(`
+paths:
+  /search:
+    get:
+      parameters:
+        - name: q
+          in: query
+          required: true
+        - name: page
+          in: query
+`), &spec)
+	if err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.ValidateRequests = true
+	router := setupRouter(config, &spec)
+
+	w := serve(router, http.MethodGet, "/v1/search?page=2")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "q") {
+		t.Errorf("Expected 400 naming the missing parameter, got %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(router, http.MethodGet, "/v1/search?q=mock"); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the required parameter, got %d", w.Code)
+	}
+
+	config.ValidateRequests = false
+	if w := serve(router, http.MethodGet, "/v1/search"); w.Code != http.StatusOK {
+		t.Errorf("Expected no validation when disabled, got %d", w.Code)
+	}
+}
+
+// TestValidateRequestsSharedParameters verifies required query parameters declared on
+// the path item or through a components $ref are checked too.
+func TestValidateRequestsSharedParameters(t *testing.T) {
+	defer func(original io.Reader) { stdin = original }(stdin)
+	stdin = strings.NewReader(`
+paths:
+  /search:
+    parameters:
+      - $ref: "#/components/parameters/Query"
+    get:
+      parameters:
+        - name: page
+          in: query
+          required: true
+components:
+  parameters:
+    Query:
+      name: q
+      in: query
+      required: true
+`)
+	spec, err := loadAPISpec("-")
+	if err != nil {
+		t.Fatalf("Expected spec to load, got error: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.ValidateRequests = true
+	router := setupRouter(config, spec)
+
+	for _, target := range []string{"/v1/search?page=2", "/v1/search?q=mock"} {
+		if w := serve(router, http.MethodGet, target); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400 for a missing required parameter, got %d", target, w.Code)
+		}
+	}
+	if w := serve(router, http.MethodGet, "/v1/search?q=mock&page=2"); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with both parameters, got %d", w.Code)
+	}
+
+	stdin = strings.NewReader("paths:\n  /search:\n    parameters:\n      - $ref: \"#/components/parameters/Missing\"\n    get: {}\n")
+	if _, err := loadAPISpec("-"); err == nil {
+		t.Error("Expected an error for an unknown parameter $ref")
+	}
+}
+
+func TestLoadAPISpecsMerged(t *testing.T) {
+	dir := t.TempDir()
+	users := dir + "/users.yaml"
+	orders := dir + "/orders.yaml"
+	if err := os.WriteFile(users, []byte("paths:\n  /users:\n    get: {}\n  /shared:\n    put: {}\n    get: {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test API spec: %v", err)
+	}
+	if err := os.WriteFile(orders, []byte("paths:\n  /orders:\n    get: {}\n  /shared:\n    post: {}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test API spec: %v", err)
+	}
+
+	spec, err := loadAPISpecs([]string{users, orders})
+	if err != nil {
+		t.Fatalf("Expected API specs to merge, got error: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	router := setupRouter(config, spec)
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/v1/users"},
+		{http.MethodGet, "/v1/orders"},
+		{http.MethodGet, "/v1/shared"},
+		{http.MethodPost, "/v1/shared"},
+	} {
+		if w := serve(router, route.method, route.path); w.Code != http.StatusOK {
+			t.Errorf("Expected %s %s to be registered, got %d", route.method, route.path, w.Code)
+		}
+	}
+
+	if _, err := loadAPISpecs([]string{users, users}); err == nil || !strings.Contains(err.Error(), "GET /shared") {
+		t.Errorf("Expected a conflict error naming GET /shared, got: %v", err)
+	}
+}
+
+func TestValidateRequestsPathParams(t *testing.T) {
+	var spec APISpec
+	err := yaml.Unmarshal([]byte(`
+paths:
+  /users/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+`), &spec)
+	if err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.ValidateRequests = true
+	router := setupRouter(config, &spec)
+
+	w := serve(router, http.MethodGet, "/v1/users/abc")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "id") {
+		t.Errorf("Expected 400 naming the invalid parameter, got %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(router, http.MethodGet, "/v1/users/42"); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for an integer id, got %d", w.Code)
+	}
+}
+
+// TestValidateRequestsPathLevelPathParams verifies path parameter types declared on the
+// path item, as path parameters usually are, are checked.
+func TestValidateRequestsPathLevelPathParams(t *testing.T) {
+	defer func(original io.Reader) { stdin = original }(stdin)
+	stdin = strings.NewReader(`
+paths:
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: integer
+    get: {}
+`)
+	spec, err := loadAPISpec("-")
+	if err != nil {
+		t.Fatalf("Expected spec to load, got error: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.ValidateRequests = true
+	router := setupRouter(config, spec)
+
+	if w := serve(router, http.MethodGet, "/v1/users/abc"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a non-integer id, got %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/v1/users/42"); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for an integer id, got %d", w.Code)
+	}
+}
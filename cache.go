@@ -0,0 +1,58 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// parseCachedOverride recognizes a response entry written as { cache_ttl: 30s, body: ... },
+// returning the body and how long its rendered form is reused.
+func parseCachedOverride(entry interface{}) (interface{}, time.Duration, bool) {
+	fields, ok := entry.(map[interface{}]interface{})
+	if !ok || len(fields) != 2 {
+		return nil, 0, false
+	}
+	body, hasBody := fields["body"]
+	value, isString := fields["cache_ttl"].(string)
+	if !hasBody || !isString {
+		return nil, 0, false
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Printf("Ignoring invalid cache_ttl %q", value)
+		return body, 0, true
+	}
+	return body, ttl, true
+}
+
+// cachedBody is a rendered response body and when it stops being reused.
+type cachedBody struct {
+	body    interface{}
+	expires time.Time
+}
+
+// bodyCache stores rendered response bodies by path until they expire.
+// It is safe for concurrent use.
+type bodyCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedBody
+}
+
+// newBodyCache creates an empty body cache.
+func newBodyCache() *bodyCache {
+	return &bodyCache{entries: make(map[string]cachedBody)}
+}
+
+// get returns the unexpired body stored under key, or renders, stores, and returns a
+// new one for ttl.
+func (c *bodyCache) get(key string, now time.Time, ttl time.Duration, render func() interface{}) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok && now.Before(entry.expires) {
+		return entry.body
+	}
+	body := render()
+	c.entries[key] = cachedBody{body: body, expires: now.Add(ttl)}
+	return body
+}
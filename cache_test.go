@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestCacheTTL verifies a cache_ttl body is reused within the TTL and rendered again after.
+func TestCacheTTL(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Templating = true
+	config.Responses = map[string]interface{}{
+		"/v1/quote": map[interface{}]interface{}{"cache_ttl": "30s", "body": `{"id": "{{uuid}}"}`},
+	}
+	clock := newFakeClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	getRuntimeState(config).clock = clock
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/quote": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	first := serve(router, http.MethodGet, "/v1/quote").Body.String()
+	clock.Advance(10 * time.Second)
+	if second := serve(router, http.MethodGet, "/v1/quote").Body.String(); second != first {
+		t.Errorf("Expected the cached body %q within the TTL, got %q", first, second)
+	}
+	clock.Advance(30 * time.Second)
+	if third := serve(router, http.MethodGet, "/v1/quote").Body.String(); third == first {
+		t.Errorf("Expected a regenerated body after the TTL, got %q again", third)
+	}
+}
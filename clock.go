@@ -0,0 +1,21 @@
+package main
+
+import "time"
+
+// Clock tells the time and waits, so time-dependent behavior can be tested without
+// real sleeps.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel that receives the time once d has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock backed by the time package.
+type realClock struct{}
+
+// Now returns time.Now().
+func (realClock) Now() time.Time { return time.Now() }
+
+// After returns time.After(d).
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
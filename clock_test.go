@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves when Advance is called.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+// fakeWaiter is a pending After call.
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// newFakeClock creates a fake clock set to now.
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+// Now returns the fake time.
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives once the clock is advanced by d.
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeWaiter{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d, releasing the waits that have elapsed.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.deadline.After(c.now) {
+			pending = append(pending, waiter)
+			continue
+		}
+		waiter.ch <- c.now
+	}
+	c.waiters = pending
+}
+
+// waiting returns the number of pending After calls.
+func (c *fakeClock) waiting() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// TestHandleRequest_FakeClockLatency verifies latency waits on the config's clock.
+func TestHandleRequest_FakeClockLatency(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 5, High: 5, Unit: "s"}
+	clock := newFakeClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	getRuntimeState(config).clock = clock
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, NewErrorSimulator(0.0))
+	}()
+
+	for clock.waiting() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(4 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("Expected the request to wait the full 5s of fake time")
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Second)
+	<-done
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+}
+
+// TestHandleRequest_FakeClockMaintenance verifies maintenance windows follow the clock.
+func TestHandleRequest_FakeClockMaintenance(t *testing.T) {
+	start := time.Date(2024, time.March, 5, 2, 0, 0, 0, time.UTC)
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Maintenance.Windows = []TimeWindow{{Start: start, End: start.Add(time.Hour)}}
+	clock := newFakeClock(start.Add(-time.Minute))
+	getRuntimeState(config).clock = clock
+
+	want := []struct {
+		advance time.Duration
+		code    int
+	}{
+		{0, http.StatusOK},
+		{time.Minute, http.StatusServiceUnavailable},
+		{time.Hour - time.Second, http.StatusServiceUnavailable},
+		{time.Second, http.StatusOK},
+	}
+	for _, step := range want {
+		clock.Advance(step.advance)
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, NewErrorSimulator(0.0))
+		if w.Code != step.code {
+			t.Errorf("At %s: expected status %d, got %d", clock.Now().Format(time.RFC3339), step.code, w.Code)
+		}
+	}
+}
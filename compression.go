@@ -0,0 +1,150 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gorilla/mux"
+)
+
+// Content encodings accepted by compression.
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+	encodingBrotli  = "br"
+)
+
+// newEncoder returns a compressing writer for a content encoding.
+func newEncoder(encoding string, w io.Writer) compressor {
+	switch encoding {
+	case encodingDeflate:
+		return zlib.NewWriter(w)
+	case encodingBrotli:
+		return brotli.NewWriter(w)
+	default:
+		return gzip.NewWriter(w)
+	}
+}
+
+// compressor is a compressing writer that can flush buffered data.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// compressionMiddleware compresses response bodies with the encoding the client prefers
+// in Accept-Encoding among the enabled ones. Ties go to the order of enabled.
+func compressionMiddleware(enabled []string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), enabled)
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the enabled encoding with the highest quality in an
+// Accept-Encoding header, or "" if the client accepts none of them. A "*" entry stands
+// for enabled encodings the header doesn't name.
+func negotiateEncoding(header string, enabled []string) string {
+	qualities := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if name == "*" {
+			wildcard = quality
+		} else if name != "" {
+			qualities[name] = quality
+		}
+	}
+	best, bestQuality := "", 0.0
+	for _, encoding := range enabled {
+		quality, ok := qualities[encoding]
+		if !ok {
+			quality = wildcard
+		}
+		if quality > bestQuality {
+			best, bestQuality = encoding, quality
+		}
+	}
+	return best
+}
+
+// compressWriter compresses the body written through it. Responses that can't have a
+// body are passed through unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	encoder     compressor
+	wroteHeader bool
+}
+
+// WriteHeader declares the encoding before passing the status through.
+func (c *compressWriter) WriteHeader(status int) {
+	if c.wroteHeader {
+		return
+	}
+	c.wroteHeader = true
+	if status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified {
+		c.Header().Set("Content-Encoding", c.encoding)
+		c.Header().Del("Content-Length")
+		c.encoder = newEncoder(c.encoding, c.ResponseWriter)
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// Write compresses the bytes before passing them through.
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if !c.wroteHeader {
+		c.WriteHeader(http.StatusOK)
+	}
+	if c.encoder == nil {
+		return c.ResponseWriter.Write(b)
+	}
+	return c.encoder.Write(b)
+}
+
+// FlushError sends the data compressed so far, so streamed responses are not held back.
+// It returns http.ErrNotSupported if the wrapped writer can't flush.
+func (c *compressWriter) FlushError() error {
+	if c.encoder != nil {
+		if err := c.encoder.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// close finishes the compressed body.
+func (c *compressWriter) close() {
+	if c.encoder != nil {
+		if err := c.encoder.Close(); err != nil {
+			log.Printf("Error finishing compressed response: %v", err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestCompression(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Compression = []string{encodingGzip, encodingDeflate, encodingBrotli}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	request := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	want := serve(router, http.MethodGet, "/v1/test").Body.String()
+
+	w := request("gzip;q=0.5, deflate")
+	if got := w.Header().Get("Content-Encoding"); got != encodingDeflate {
+		t.Fatalf("Expected Content-Encoding deflate, got %q", got)
+	}
+	deflated, err := zlib.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected a deflate body, got error: %v", err)
+	}
+	if got, _ := io.ReadAll(deflated); string(got) != want {
+		t.Errorf("Expected deflate body %q, got %q", want, got)
+	}
+
+	w = request("gzip;q=0.5, br")
+	if got := w.Header().Get("Content-Encoding"); got != encodingBrotli {
+		t.Fatalf("Expected Content-Encoding br, got %q", got)
+	}
+	if got, _ := io.ReadAll(brotli.NewReader(w.Body)); string(got) != want {
+		t.Errorf("Expected Brotli body %q, got %q", want, got)
+	}
+
+	w = request("gzip, deflate;q=0.8")
+	if got := w.Header().Get("Content-Encoding"); got != encodingGzip {
+		t.Fatalf("Expected Content-Encoding gzip, got %q", got)
+	}
+	reader, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Expected a gzip body, got error: %v", err)
+	}
+	if got, _ := io.ReadAll(reader); string(got) != want {
+		t.Errorf("Expected gzip body %q, got %q", want, got)
+	}
+
+	if w := request("identity"); w.Header().Get("Content-Encoding") != "" || w.Body.String() != want {
+		t.Errorf("Expected an uncompressed body, got %q encoded as %q", w.Body.String(), w.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	enabled := []string{encodingGzip, encodingDeflate, encodingBrotli}
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"br", encodingBrotli},
+		{"deflate, gzip", encodingGzip},
+		{"gzip;q=0, deflate;q=0.1", encodingDeflate},
+		{"*", encodingGzip},
+		{"gzip;q=0, *;q=0.5", encodingDeflate},
+		{"zstd", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header, enabled); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.header, tt.want, got)
+		}
+	}
+}
+
+// TestBrotliLargeBody verifies a large response is actually compressed and decodes back
+// to the original body.
+func TestBrotliLargeBody(t *testing.T) {
+	body := strings.Repeat("mock-api ", 20000)
+	handler := compressionMiddleware([]string{encodingBrotli})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+	req.Header.Set("Accept-Encoding", "br")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Body.Len() >= len(body)/10 {
+		t.Errorf("Expected the body to shrink, got %d of %d bytes", w.Body.Len(), len(body))
+	}
+	got, err := io.ReadAll(brotli.NewReader(w.Body))
+	if err != nil {
+		t.Fatalf("Failed to decode Brotli body: %v", err)
+	}
+	if string(got) != body {
+		t.Errorf("Expected %d bytes back, got %d", len(body), len(got))
+	}
+}
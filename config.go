@@ -1,47 +1,441 @@
 package main
 
 import (
+	"encoding/base64"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 // Config holds our configuration.
 type Config struct {
-	// Which API spec (YAML) to load.
-	APISpec string `yaml:"api_spec"`
-	// Latency configuration.
+	// Which API spec (YAML) to load, or a list of specs merged into one.
+	APISpec SpecSources `yaml:"api_spec" schema:"required"`
+	// Latency configuration. Required unless latency_optional is set, which is checked
+	// when the config loads rather than in the schema.
 	Latency LatencyConfig `yaml:"latency"`
+	// Allow leaving out the latency block, which then means responses are immediate.
+	LatencyOptional bool `yaml:"latency_optional"`
 	// Override responses for specific endpoints.
 	Responses map[string]interface{} `yaml:"responses"`
+	// Directory of response files laid out as METHOD/path.json, e.g. GET/v1/users.json.
+	ResponsesDir string `yaml:"responses_dir"`
+	// HAR file whose recorded responses are replayed, keyed by method and URL path.
+	HAR string `yaml:"har"`
 	// ErrorResponse now contains the error code, body, and frequency.
-	ErrorResponse ErrorResponseConfig `yaml:"error_response"`
-	// Prefix to insert before each endpoint URL. Defaults to "v1" if not provided.
+	ErrorResponse ErrorResponseConfig `yaml:"error_response" schema:"required"`
+	// Prefix to insert before each endpoint URL. Defaults to the base path of the spec's
+	// first server, or "v1" if not provided.
 	Prefix string `yaml:"prefix"`
+	// Honor the X-HTTP-Method-Override header when resolving per-method responses.
+	MethodOverride bool `yaml:"method_override"`
+	// Keep numbers in JSON string overrides as written instead of converting them to float64.
+	UseJSONNumber bool `yaml:"use_json_number"`
+	// Also write the object keys of JSON bodies served as-is (file, body_base64, and HAR
+	// recordings) in sorted order. Other responses are always sorted.
+	SortKeys bool `yaml:"sort_keys"`
+	// JSON key holding the message in error envelopes. Defaults to "error" if not provided.
+	ErrorKey string `yaml:"error_key"`
+	// How a null response override is served: "default" (the default message), "empty" ({}), or "null".
+	NullResponse string `yaml:"null_response"`
+	// Name of the variant served from response entries with profiles, e.g. "staging".
+	// Entries without a variant for it serve their base body.
+	Profile string `yaml:"profile"`
+	// Allow individual requests to adjust mock behavior via query parameters (e.g. force_error).
+	RequestOverrides bool `yaml:"request_overrides"`
+	// Start with a warning instead of failing when the API spec defines no paths.
+	AllowEmptySpec bool `yaml:"allow_empty_spec"`
+	// Answer requests missing a query parameter the spec declares as required with 400.
+	ValidateRequests bool `yaml:"validate_requests"`
+	// Body served at the prefix root (e.g. GET /v1), such as an API index or banner.
+	RootResponse interface{} `yaml:"root_response"`
+	// Behavior options for specific endpoints, keyed like responses.
+	Endpoints map[string]EndpointConfig `yaml:"endpoints"`
+	// Cross-origin resource sharing headers and preflight responses.
+	CORS CORSConfig `yaml:"cors"`
+	// Options for streamed (server-sent events) responses.
+	Streaming StreamingConfig `yaml:"streaming"`
+	// Render {{ ... }} templates (uuid, randomInt, now) in response bodies.
+	Templating bool `yaml:"templating"`
+	// How long a response is replayed for repeated requests with the same Idempotency-Key.
+	// Zero disables replay.
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+
+	// Value of the Server header on every response, e.g. "nginx/1.25".
+	ServerHeader string `yaml:"server_header"`
+	// Offset of the Date header on every response from the real time, e.g. "-5m".
+	DateSkew time.Duration `yaml:"date_skew"`
+	// Scheduled downtime during which every request gets 503.
+	Maintenance MaintenanceConfig `yaml:"maintenance"`
+	// Maximum number of requests handled at once; further requests get 503. Zero means no limit.
+	MaxConcurrent int `yaml:"max_concurrent"`
+	// Requests allowed per client IP in each window; further requests get 429.
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	// Client IPs or CIDR ranges allowed to connect; others get 403. Empty allows everyone.
+	IPAllowlist []string `yaml:"ip_allowlist"`
+	// Client IPs or CIDR ranges that get 403, even if allowlisted.
+	IPDenylist []string `yaml:"ip_denylist"`
+	// Redirect between /path and /path/ (the default). When false, both forms are served directly.
+	StrictSlash *bool `yaml:"strict_slash"`
+	// How /path/ relates to /path: "redirect" (the default), "ignore" to serve both forms
+	// the same response, or "strict" to treat them as distinct routes. Overrides strict_slash.
+	TrailingSlash string `yaml:"trailing_slash"`
+	// Write a line per request to stdout: "common", "combined", or "json". Empty disables it.
+	AccessLogFormat string `yaml:"access_log_format"`
+	// Content type of JSON responses, e.g. "application/vnd.api+json". Defaults to "application/json".
+	JSONContentType string `yaml:"json_content_type"`
+	// Largest accepted request body in bytes; larger requests get 413. Zero means no limit.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	// Rate in kilobits per second at which request bodies are read before responding,
+	// simulating a slow server. Zero reads at full speed.
+	SlowReadKbps float64 `yaml:"slow_read_kbps"`
+	// Reject requests whose Accept header excludes the response type with 406. When false,
+	// such requests are served the response anyway.
+	StrictAccept bool `yaml:"strict_accept"`
+	// Content encodings offered to clients that accept them: "gzip", "deflate", or "br".
+	// When a client accepts several equally, the first listed wins.
+	Compression []string `yaml:"compression"`
+	// Settings of the HTTPS listener started with --tls-port.
+	TLS TLSConfig `yaml:"tls"`
+
+	// Mutable state shared by the handlers, created on first use.
+	state atomic.Pointer[runtimeState]
+}
+
+// StreamingConfig holds options applied to streamed responses.
+type StreamingConfig struct {
+	// Query parameter that requests streaming. Defaults to "stream".
+	TriggerParam string `yaml:"trigger_param"`
+	// Value of the trigger parameter that requests streaming. Defaults to "true".
+	TriggerValue string `yaml:"trigger_value"`
+	// HTTP trailers written after the stream completes.
+	Trailers map[string]string `yaml:"trailers"`
+	// How many times the chunks are sent before the done marker: a count or "infinite".
+	Repeat RepeatCount `yaml:"repeat"`
+	// Content of the terminal data frame. Defaults to "[DONE]"; an empty string disables it.
+	DoneMarker *string `yaml:"done_marker"`
+	// Delay between chunks, sampled independently of the request latency, which is used
+	// when unset.
+	Jitter *LatencyConfig `yaml:"jitter"`
+	// Probability, from 0 to 1, that each data frame is silently dropped. The done marker
+	// is always sent. Must be below 1 with max_events or infinite repeat.
+	DropProbability float64 `yaml:"drop_probability"`
+	// Template, e.g. "You said: {{.Body.prompt}}", whose rendered words replace the
+	// response body as the stream, one {"token": word} data frame each.
+	Tokens string `yaml:"tokens"`
+	// Stream an array body one element per data frame instead of splitting its JSON.
+	ArrayElements bool `yaml:"array_elements"`
+	// Largest number of data frames sent before the done marker, across repeats. Frames
+	// dropped by drop_probability don't count. Zero means no limit.
+	MaxEvents int `yaml:"max_events"`
+}
+
+// RepeatCount is how many times a stream is sent. Zero and one both mean once.
+type RepeatCount int
+
+// InfiniteRepeat streams until the client disconnects.
+const InfiniteRepeat RepeatCount = -1
+
+// UnmarshalYAML accepts either an integer count or the string "infinite".
+func (r *RepeatCount) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var count int
+	if err := unmarshal(&count); err == nil {
+		*r = RepeatCount(count)
+		return nil
+	}
+	var value string
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	if value != "infinite" {
+		return fmt.Errorf("invalid streaming.repeat %q: must be a count or \"infinite\"", value)
+	}
+	*r = InfiniteRepeat
+	return nil
+}
+
+// continues reports whether another iteration should be streamed after completed ones.
+func (r RepeatCount) continues(completed int) bool {
+	return r == InfiniteRepeat || completed < int(r) || completed == 0
+}
+
+// EndpointConfig holds behavior options for a single endpoint.
+type EndpointConfig struct {
+	// Alternative bodies served when a request matches, checked in order before the response.
+	Rules []ResponseRule `yaml:"rules"`
+	// Bodies keyed by language tag, selected by the Accept-Language header.
+	// Requests matching no language fall back to the response.
+	Localized map[string]interface{} `yaml:"localized"`
+	// Latency for this endpoint, replacing the global latency block.
+	Latency *LatencyConfig `yaml:"latency"`
+	// File whose contents are streamed as the response body instead of a JSON response.
+	File string `yaml:"file"`
+	// Base64-encoded bytes served as-is instead of a JSON response, e.g. a protobuf message.
+	BodyBase64 string `yaml:"body_base64"`
+	// Content type of the response. For a file or base64 body it defaults to one derived
+	// from the file extension, or application/octet-stream; JSON bodies default to
+	// json_content_type.
+	ContentType string `yaml:"content_type"`
+	// Serve body_base64 as a unary gRPC-Web response, framed with trailers.
+	GRPCWeb bool `yaml:"grpc_web"`
+	// gRPC status code and message sent in the gRPC-Web trailers. Defaults to 0 (OK).
+	GRPCStatus  int    `yaml:"grpc_status"`
+	GRPCMessage string `yaml:"grpc_message"`
+	// Preflight policy for this path, overriding the global CORS policy.
+	CORS *CORSPolicy `yaml:"cors"`
+	// Status code of successful responses. Defaults to 200.
+	Status int `yaml:"status"`
+	// Extra headers sent with successful responses.
+	Headers map[string]string `yaml:"headers"`
+	// Never simulate random errors for this endpoint, whatever the error frequency.
+	NoErrors bool `yaml:"no_errors"`
+	// Only wait out the latency, then send an empty 200 without building a body.
+	DelayOnly bool `yaml:"delay_only"`
+	// Extra delay in milliseconds for the first request to the path after startup or an
+	// admin reset, simulating a cold start.
+	ColdStartMs int `yaml:"cold_start_ms"`
+	// Fail the first N attempts with the error response, then succeed. Attempts carrying an
+	// Idempotency-Key header are counted per key.
+	FailFirst uint64 `yaml:"fail_first"`
+	// Fail exactly every Nth request with the error response, independent of the error frequency.
+	EveryNthError uint64 `yaml:"every_nth_error"`
+	// ETag sent with successful responses. PUT, PATCH, and DELETE requests whose If-Match
+	// header doesn't match it get 412.
+	ETag string `yaml:"etag"`
+	// Request content types the endpoint accepts, e.g. [application/json]; requests with a
+	// body of another type get 415, and JSON bodies that don't parse when application/json
+	// is listed get 400. Empty accepts any type.
+	Accepts []string `yaml:"accepts"`
+	// Largest accepted request body, overriding the global max_body_bytes.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	// Body of the 413 sent for requests over the limit. Defaults to a JSON error message.
+	TooLargeBody interface{} `yaml:"too_large_body"`
+}
+
+// ResponseRule serves Body for requests matching every condition in When.
+type ResponseRule struct {
+	When RuleConditions `yaml:"when"`
+	Body interface{}    `yaml:"body"`
+}
+
+// RuleConditions are the request conditions of a response rule.
+type RuleConditions struct {
+	// Header values that must match exactly, ignoring case.
+	Header map[string]string `yaml:"header"`
+	// Headers that must be present, whatever their value.
+	HeaderPresent []string `yaml:"header_present"`
+	// Matches only the first N responses served for the path, e.g. while "initializing".
+	RequestCountLT uint64 `yaml:"request_count_lt"`
+	// Matches only request bodies larger than this many bytes, e.g. uploads handled async.
+	BodySizeGT int64 `yaml:"body_size_gt"`
+	// Top-level request body fields that must match exactly. The body is parsed as JSON,
+	// form data, or XML according to its Content-Type.
+	Body map[string]string `yaml:"body"`
+	// Query parameter values, a single value or a list per parameter, matched per QueryMode.
+	Query map[string]QueryValues `yaml:"query"`
+	// How repeated query parameters match: "any" (the default) needs one of the listed
+	// values to be sent, "all" needs every listed value to be sent.
+	QueryMode string `yaml:"query_mode"`
+}
+
+// SpecSources lists the files or URLs of the API specs to serve.
+type SpecSources []string
+
+// UnmarshalYAML accepts either a single source or a list of sources.
+func (s *SpecSources) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var source string
+	if err := unmarshal(&source); err == nil {
+		*s = SpecSources{source}
+		return nil
+	}
+	var sources []string
+	if err := unmarshal(&sources); err != nil {
+		return err
+	}
+	*s = sources
+	return nil
+}
+
+// String returns the sources separated by commas.
+func (s SpecSources) String() string {
+	return strings.Join(s, ", ")
+}
+
+// QueryValues lists the values a query parameter is matched against.
+type QueryValues []string
+
+// UnmarshalYAML accepts either a single value or a list of values.
+func (q *QueryValues) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		*q = QueryValues{value}
+		return nil
+	}
+	var values []string
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+	*q = values
+	return nil
+}
+
+// MaintenanceConfig schedules downtime windows and sets the body served during them.
+// Maintenance can also be toggled at runtime through /admin/maintenance.
+type MaintenanceConfig struct {
+	Windows []TimeWindow `yaml:"windows"`
+	// Body of the 503 response. Defaults to an error envelope if not provided.
+	Body interface{} `yaml:"body"`
+}
+
+// RateLimitConfig limits each client IP to Requests requests per fixed Window.
+type RateLimitConfig struct {
+	// Requests allowed per window. Zero disables rate limiting.
+	Requests int `yaml:"requests"`
+	// Length of each window, e.g. "1m".
+	Window time.Duration `yaml:"window"`
+}
+
+// TimeWindow is a span of wall-clock time from Start (inclusive) to End (exclusive).
+type TimeWindow struct {
+	Start time.Time `yaml:"start"`
+	End   time.Time `yaml:"end"`
+}
+
+// contains reports whether t falls within the window.
+func (w TimeWindow) contains(t time.Time) bool {
+	return !t.Before(w.Start) && t.Before(w.End)
+}
+
+// CORSConfig enables CORS support and sets the global preflight policy.
+type CORSConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Origins allowed to make cross-origin requests. Defaults to "*" if not provided.
+	AllowedOrigins []string `yaml:"allowed_origins"`
+	CORSPolicy     `yaml:",inline"`
+}
+
+// TLSConfig holds settings of the HTTPS listener.
+type TLSConfig struct {
+	// Oldest TLS version accepted: "1.0", "1.1", "1.2" (the default), or "1.3".
+	MinVersion string `yaml:"min_version"`
+}
+
+// CORSPolicy lists the methods and headers a preflight response allows.
+// Empty lists fall back to the path's methods and the requested headers.
+type CORSPolicy struct {
+	AllowedMethods []string `yaml:"allowed_methods"`
+	AllowedHeaders []string `yaml:"allowed_headers"`
 }
 
 // LatencyConfig specifies two latency values (in milliseconds)
 // and the frequency of using the low latency.
 type LatencyConfig struct {
+	Low  float64 `yaml:"low" schema:"required"`
+	High float64 `yaml:"high" schema:"required"`
+	// Unit of Low and High: "ms" (default) or "s".
+	Unit string `yaml:"unit"`
+	// How latency is sampled between Low and High: "uniform" (default), "normal", or "exponential".
+	Distribution string `yaml:"distribution"`
+	// Daily time ranges with their own Low and High, e.g. a slow peak hour.
+	Schedule []LatencyScheduleEntry `yaml:"schedule"`
+	// File of recorded latency values, one per line in Unit, sampled with replacement
+	// instead of the range.
+	Profile string `yaml:"profile"`
+
+	// Values loaded from Profile.
+	samples []float64
+}
+
+// LatencyScheduleEntry replaces Low and High between two times of day, in local time.
+// A range ending before it starts wraps past midnight.
+type LatencyScheduleEntry struct {
+	// Start and end as "HH:MM"; the end is exclusive.
+	From string  `yaml:"from"`
+	To   string  `yaml:"to"`
 	Low  float64 `yaml:"low"`
 	High float64 `yaml:"high"`
 }
 
 // ErrorResponseConfig now includes Frequency.
 type ErrorResponseConfig struct {
-	Code      int         `yaml:"code"`
-	Body      interface{} `yaml:"body"`
-	Frequency float64     `yaml:"frequency"`
+	// Status code of simulated errors. Required unless codes or family is set.
+	Code int `yaml:"code" schema:"required_unless=codes,family"`
+	// Status codes sampled among for each simulated error, replacing Code.
+	Codes []int `yaml:"codes"`
+	// Status family sampled among when Codes is empty: "4xx" or "5xx", replacing Code.
+	Family string `yaml:"family"`
+	// Error body, or a list of bodies cycled through on consecutive errors.
+	Body      interface{} `yaml:"body" schema:"required"`
+	Frequency float64     `yaml:"frequency" schema:"required"`
+	// Methods that may randomly fail, e.g. [POST, PUT, DELETE]. Empty means every method.
+	OnlyMethods []string `yaml:"only_methods"`
+	// Number of requests to each path served before random errors start.
+	Warmup uint64 `yaml:"warmup"`
+	// Latency of simulated errors, replacing the normal latency, e.g. to mimic timeouts.
+	Latency *LatencyConfig `yaml:"latency"`
+	// Probability, per simulated status, of dropping the connection without a response
+	// instead, e.g. {503: 0.3} to mimic an overloaded load balancer.
+	ResetProbability map[int]float64 `yaml:"reset_probability"`
+}
+
+// stdinName is the file name that stands for standard input.
+const stdinName = "-"
+
+// stdin is where "-" file names are read from, replaceable in tests.
+var stdin io.Reader = os.Stdin
+
+// readFileOrStdin reads the named file, or standard input if the name is "-".
+func readFileOrStdin(filename string) ([]byte, error) {
+	if filename == stdinName {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(filename)
 }
 
-// loadConfig reads and parses the YAML config file and returns an error if any required field is missing.
+// ConfigValidationError is returned by loadConfig when required fields are missing or a
+// field is invalid, so callers can tell which ones.
+type ConfigValidationError struct {
+	// Missing fields in dotted form, e.g. "latency.low".
+	Fields []string
+	// Invalid fields in the same form, e.g. "trailing_slash".
+	Invalid []string
+	// Why the invalid fields were rejected.
+	reason string
+}
+
+func (e *ConfigValidationError) Error() string {
+	if len(e.Invalid) > 0 {
+		return e.reason
+	}
+	return "missing required configuration values: " + strings.Join(e.Fields, ", ")
+}
+
+// invalidField returns a ConfigValidationError for a field rejected for the formatted reason.
+func invalidField(field, format string, args ...interface{}) error {
+	return &ConfigValidationError{Invalid: []string{field}, reason: fmt.Sprintf(format, args...)}
+}
+
+// loadConfig reads and parses the YAML (or, for a .json5 file, JSON5) config file and returns a
+// ConfigValidationError if any required field is missing or a field is invalid.
 func loadConfig(filename string) (*Config, error) {
-	data, err := os.ReadFile(filename)
+	data, err := readFileOrStdin(filename)
 	if err != nil {
 		return nil, fmt.Errorf("error reading config file: %v", err)
 	}
+	if strings.EqualFold(filepath.Ext(filename), ".json5") {
+		if data, err = json5ToJSON(data); err != nil {
+			return nil, fmt.Errorf("error parsing config file: %v", err)
+		}
+	}
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("error parsing config file: %v", err)
@@ -50,39 +444,257 @@ func loadConfig(filename string) (*Config, error) {
 	// Check for missing required fields.
 	missing := checkMissingConfig(&config)
 	if len(missing) > 0 {
-		return nil, fmt.Errorf("missing required configuration values: %s", strings.Join(missing, ", "))
+		return nil, &ConfigValidationError{Fields: missing}
+	}
+
+	if err := validateLatency("latency", config.Latency); err != nil {
+		return nil, err
+	}
+	if err := loadLatencyProfile("latency", &config.Latency); err != nil {
+		return nil, err
+	}
+	for key, endpoint := range config.Endpoints {
+		if endpoint.Latency != nil {
+			name := fmt.Sprintf("endpoints[%s].latency", key)
+			if err := validateLatency(name, *endpoint.Latency); err != nil {
+				return nil, err
+			}
+			if err := loadLatencyProfile(name, endpoint.Latency); err != nil {
+				return nil, err
+			}
+		}
+		for i, rule := range endpoint.Rules {
+			switch rule.When.QueryMode {
+			case "", queryModeAny, queryModeAll:
+			default:
+				return nil, invalidField(fmt.Sprintf("endpoints[%s].rules[%d].when.query_mode", key, i), "invalid endpoints[%s].rules[%d].when.query_mode %q: must be \"any\" or \"all\"", key, i, rule.When.QueryMode)
+			}
+		}
+		if _, err := base64.StdEncoding.DecodeString(endpoint.BodyBase64); err != nil {
+			return nil, invalidField(fmt.Sprintf("endpoints[%s].body_base64", key), "invalid endpoints[%s].body_base64: %v", key, err)
+		}
+	}
+
+	if config.Streaming.Jitter != nil {
+		if err := validateLatency("streaming.jitter", *config.Streaming.Jitter); err != nil {
+			return nil, err
+		}
+		if err := loadLatencyProfile("streaming.jitter", config.Streaming.Jitter); err != nil {
+			return nil, err
+		}
+	}
+	if config.ErrorResponse.Latency != nil {
+		if err := validateLatency("error_response.latency", *config.ErrorResponse.Latency); err != nil {
+			return nil, err
+		}
+		if err := loadLatencyProfile("error_response.latency", config.ErrorResponse.Latency); err != nil {
+			return nil, err
+		}
+	}
+
+	switch config.ErrorResponse.Family {
+	case "", errorFamilyClient, errorFamilyServer:
+	default:
+		return nil, invalidField("error_response.family", "invalid error_response.family %q: must be \"4xx\" or \"5xx\"", config.ErrorResponse.Family)
+	}
+	for _, code := range config.ErrorResponse.Codes {
+		if code < 400 || code > 599 {
+			return nil, invalidField("error_response.codes", "invalid error_response.codes entry %d: must be a 4xx or 5xx status", code)
+		}
+	}
+
+	switch config.NullResponse {
+	case "", "default", "empty", "null":
+	default:
+		return nil, invalidField("null_response", "invalid null_response %q: must be \"default\", \"empty\", or \"null\"", config.NullResponse)
+	}
+
+	for _, encoding := range config.Compression {
+		switch encoding {
+		case encodingGzip, encodingDeflate, encodingBrotli:
+		default:
+			return nil, invalidField("compression", "invalid compression %q: must be \"gzip\", \"deflate\", or \"br\"", encoding)
+		}
+	}
+
+	if _, err := tlsVersion(config.TLS.MinVersion); err != nil {
+		return nil, invalidField("tls.min_version", "%v", err)
+	}
+
+	if config.RateLimit.Requests < 0 || (config.RateLimit.Requests > 0 && config.RateLimit.Window <= 0) {
+		return nil, invalidField("rate_limit", "invalid rate_limit: requests must not be negative and window must be positive")
+	}
+	if _, err := parseIPRanges(config.IPAllowlist); err != nil {
+		return nil, invalidField("ip_allowlist", "invalid ip_allowlist: %v", err)
+	}
+	if _, err := parseIPRanges(config.IPDenylist); err != nil {
+		return nil, invalidField("ip_denylist", "invalid ip_denylist: %v", err)
+	}
+
+	if p := config.Streaming.DropProbability; p < 0 || p > 1 {
+		return nil, invalidField("streaming.drop_probability", "invalid streaming.drop_probability %v: must be between 0 and 1", p)
+	}
+	if config.Streaming.DropProbability == 1 && (config.Streaming.MaxEvents > 0 || config.Streaming.Repeat == InfiniteRepeat) {
+		// Every frame would be dropped, so the stream would never reach its end.
+		return nil, invalidField("streaming.drop_probability", "invalid streaming.drop_probability 1: must be below 1 with max_events or infinite repeat")
+	}
+
+	switch config.TrailingSlash {
+	case "", trailingSlashRedirect, trailingSlashIgnore, trailingSlashStrict:
+	default:
+		return nil, invalidField("trailing_slash", "invalid trailing_slash %q: must be \"redirect\", \"ignore\", or \"strict\"", config.TrailingSlash)
+	}
+
+	switch config.AccessLogFormat {
+	case "", accessLogCommon, accessLogCombined, accessLogJSON:
+	default:
+		return nil, invalidField("access_log_format", "invalid access_log_format %q: must be \"common\", \"combined\", or \"json\"", config.AccessLogFormat)
 	}
 
 	// For optional fields, initialize defaults if needed.
 	if config.Responses == nil {
 		config.Responses = make(map[string]interface{})
 	}
+	if config.ResponsesDir != "" {
+		if err := loadResponsesDir(config.ResponsesDir, config.Responses); err != nil {
+			return nil, err
+		}
+	}
+	if config.HAR != "" {
+		if err := loadHAR(config.HAR, &config); err != nil {
+			return nil, err
+		}
+	}
 
 	return &config, nil
 }
 
+// loadResponsesDir adds a response for every file under dir, keyed by method and path:
+// GET/v1/users.json becomes "GET /v1/users". JSON files are served as-is and YAML files
+// are parsed. Entries already in responses take precedence.
+func loadResponsesDir(dir string, responses map[string]interface{}) error {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		method, file, ok := strings.Cut(filepath.ToSlash(rel), "/")
+		if !ok {
+			return nil
+		}
+		ext := filepath.Ext(file)
+		key := strings.ToUpper(method) + " /" + strings.TrimSuffix(file, ext)
+		if _, exists := responses[key]; exists {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		switch ext {
+		case ".json":
+			responses[key] = string(data)
+		case ".yaml", ".yml":
+			var body interface{}
+			if err := yaml.Unmarshal(data, &body); err != nil {
+				return fmt.Errorf("error parsing %s: %v", path, err)
+			}
+			responses[key] = body
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error loading responses_dir: %v", err)
+	}
+	return nil
+}
+
+// getEndpointConfig returns the options for an endpoint, preferring a "METHOD /path"
+// entry over a plain "/path" entry.
+func getEndpointConfig(method, path string, config *Config) EndpointConfig {
+	path = strings.TrimRight(path, "/")
+	if endpoint, ok := config.Endpoints[method+" "+path]; ok {
+		return endpoint
+	}
+	return config.Endpoints[path]
+}
+
+// validateLatency checks the unit and distribution of a latency block named name.
+func validateLatency(name string, latency LatencyConfig) error {
+	switch latency.Unit {
+	case "", "ms", "s":
+	default:
+		return invalidField(name+".unit", "invalid %s.unit %q: must be \"ms\" or \"s\"", name, latency.Unit)
+	}
+	switch latency.Distribution {
+	case "", distributionUniform, distributionNormal, distributionExponential:
+	default:
+		return invalidField(name+".distribution", "invalid %s.distribution %q: must be \"uniform\", \"normal\", or \"exponential\"", name, latency.Distribution)
+	}
+	for i, entry := range latency.Schedule {
+		for _, value := range []string{entry.From, entry.To} {
+			if _, err := time.Parse(timeOfDayFormat, value); err != nil {
+				return invalidField(fmt.Sprintf("%s.schedule[%d]", name, i), "invalid %s.schedule[%d] time %q: must be HH:MM", name, i, value)
+			}
+		}
+	}
+	return nil
+}
+
+// loadLatencyProfile reads the samples of a latency block's profile file, if it has one.
+// Blank lines are skipped.
+func loadLatencyProfile(name string, latency *LatencyConfig) error {
+	if latency.Profile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(latency.Profile)
+	if err != nil {
+		return fmt.Errorf("error reading %s.profile: %v", name, err)
+	}
+	latency.samples = nil
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(line, 64)
+		if err != nil || value < 0 {
+			return invalidField(name+".profile", "invalid %s.profile value %q on line %d", name, line, i+1)
+		}
+		latency.samples = append(latency.samples, value)
+	}
+	if len(latency.samples) == 0 {
+		return invalidField(name+".profile", "invalid %s.profile: no latency values in %s", name, latency.Profile)
+	}
+	return nil
+}
+
 func checkMissingConfig(config *Config) []string {
 	var missing []string
-	if strings.TrimSpace(config.APISpec) == "" {
+	if strings.TrimSpace(strings.Join(config.APISpec, "")) == "" {
 		missing = append(missing, "api_spec")
 	}
-	if config.Latency.Low == 0 {
+	// With latency_optional, leaving the latency block out means no latency, but a
+	// block that is given must still be complete.
+	latencyOmitted := config.LatencyOptional && reflect.ValueOf(config.Latency).IsZero()
+	if config.Latency.Low == 0 && config.Latency.Profile == "" && !latencyOmitted {
 		missing = append(missing, "latency.low")
 	}
-	if config.Latency.High == 0 {
+	if config.Latency.High == 0 && config.Latency.Profile == "" && !latencyOmitted {
 		missing = append(missing, "latency.high")
 	}
 	if config.ErrorResponse.Frequency == 0 {
 		missing = append(missing, "error_response.frequency")
 	}
-	if config.ErrorResponse.Code == 0 {
+	if config.ErrorResponse.Code == 0 && len(config.ErrorResponse.Codes) == 0 && config.ErrorResponse.Family == "" {
 		missing = append(missing, "error_response.code")
 	}
 	if config.ErrorResponse.Body == nil {
 		missing = append(missing, "error_response.body")
 	}
-	if strings.TrimSpace(config.Prefix) == "" {
-		missing = append(missing, "prefix")
-	}
 	return missing
 }
@@ -1,9 +1,17 @@
 package main
 
 import (
+	"errors"
+	"io"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
 )
 
 const validConfig = `
@@ -33,7 +41,7 @@ func TestLoadConfigValid(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Expected config to load, got error: %v", err)
 	}
-	if config.APISpec != "spec.yaml" {
+	if config.APISpec.String() != "spec.yaml" {
 		t.Errorf("Expected api_spec to be spec.yaml, got: %s", config.APISpec)
 	}
 	if config.Latency.Low != 100 {
@@ -67,12 +75,19 @@ prefix: ""
 	if err == nil {
 		t.Fatal("Expected error for missing config values, got nil")
 	}
-	expectedFields := []string{"api_spec", "latency.low", "latency.high", "error_response.frequency", "error_response.code", "error_response.body", "prefix"}
+	expectedFields := []string{"api_spec", "latency.low", "latency.high", "error_response.frequency", "error_response.code", "error_response.body"}
 	for _, field := range expectedFields {
 		if !strings.Contains(err.Error(), field) {
 			t.Errorf("Expected error message to contain %s", field)
 		}
 	}
+	var validationErr *ConfigValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a *ConfigValidationError, got %T", err)
+	}
+	if !reflect.DeepEqual(validationErr.Fields, expectedFields) {
+		t.Errorf("Expected fields %v, got %v", expectedFields, validationErr.Fields)
+	}
 }
 
 func TestLoadConfigMissingSections(t *testing.T) {
@@ -93,12 +108,19 @@ prefix: ""
 	if err == nil {
 		t.Fatal("Expected error for missing config values, got nil")
 	}
-	expectedFields := []string{"api_spec", "latency.low", "latency.high", "error_response.frequency", "error_response.code", "error_response.body", "prefix"}
+	expectedFields := []string{"api_spec", "latency.low", "latency.high", "error_response.frequency", "error_response.code", "error_response.body"}
 	for _, field := range expectedFields {
 		if !strings.Contains(err.Error(), field) {
 			t.Errorf("Expected error message to contain %s", field)
 		}
 	}
+	var validationErr *ConfigValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a *ConfigValidationError, got %T", err)
+	}
+	if !reflect.DeepEqual(validationErr.Fields, expectedFields) {
+		t.Errorf("Expected fields %v, got %v", expectedFields, validationErr.Fields)
+	}
 }
 
 func TestLoadConfigFileNotFound(t *testing.T) {
@@ -144,10 +166,265 @@ prefix: ""
 	if err == nil {
 		t.Fatal("Expected error for missing config values, got nil")
 	}
-	expectedFields := []string{"latency.low", "latency.high", "error_response.body", "prefix"}
+	expectedFields := []string{"latency.low", "latency.high", "error_response.body"}
 	for _, field := range expectedFields {
 		if !strings.Contains(err.Error(), field) {
 			t.Errorf("Expected error message to contain %s", field)
 		}
 	}
 }
+
+func TestLoadConfigInvalidLatencyUnit(t *testing.T) {
+	filename := "test_unit_config.yaml"
+	config := strings.Replace(validConfig, "high: 1000", "high: 1000\n  unit: minutes", 1)
+	if err := os.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	defer os.Remove(filename)
+
+	_, err := loadConfig(filename)
+	if err == nil || !strings.Contains(err.Error(), "latency.unit") {
+		t.Fatalf("Expected invalid latency.unit error, got: %v", err)
+	}
+	var validationErr *ConfigValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a *ConfigValidationError, got %T", err)
+	}
+	if want := []string{"latency.unit"}; !reflect.DeepEqual(validationErr.Invalid, want) {
+		t.Errorf("Expected invalid fields %v, got %v", want, validationErr.Invalid)
+	}
+}
+
+func TestStreamingRepeatYAML(t *testing.T) {
+	tests := []struct {
+		input string
+		want  RepeatCount
+	}{
+		{"repeat: 3", 3},
+		{"repeat: infinite", InfiniteRepeat},
+	}
+	for _, tt := range tests {
+		var streaming StreamingConfig
+		if err := yaml.Unmarshal([]byte(tt.input), &streaming); err != nil {
+			t.Fatalf("Failed to parse %q: %v", tt.input, err)
+		}
+		if streaming.Repeat != tt.want {
+			t.Errorf("Parsed %q as %d, want %d", tt.input, streaming.Repeat, tt.want)
+		}
+	}
+
+	var streaming StreamingConfig
+	if err := yaml.Unmarshal([]byte("repeat: forever"), &streaming); err == nil {
+		t.Error("Expected an error for an invalid repeat value")
+	}
+}
+
+func TestLoadConfigResponsesDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"GET/v1/users.json":      `{"users":["ada","grace"]}`,
+		"POST/v1/users.yaml":     "created: true\n",
+		"GET/v1/test.json":       `{"message":"from file"}`,
+		"GET/v1/nested/a/b.json": `{"deep":true}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write response file: %v", err)
+		}
+	}
+
+	filename := "test_responses_dir_config.yaml"
+	config := `
+api_spec: "spec.yaml"
+latency:
+  low: 100
+  high: 1000
+responses:
+  "GET /v1/test": '{"message":"from config"}'
+error_response:
+  code: 500
+  body:
+    error: "simulated error"
+  frequency: 0.05
+responses_dir: "` + dir + `"
+`
+	if err := os.WriteFile(filename, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	defer os.Remove(filename)
+
+	loaded, err := loadConfig(filename)
+	if err != nil {
+		t.Fatalf("Expected config to load, got error: %v", err)
+	}
+	loaded.Latency = LatencyConfig{Low: 0, High: 0}
+
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{"GET", "/v1/users", `{"users":["ada","grace"]}`},
+		{"POST", "/v1/users", `{"created":true}`},
+		{"GET", "/v1/nested/a/b", `{"deep":true}`},
+		{"GET", "/v1/test", `{"message":"from config"}`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://example.com"+tt.path, nil)
+		w := httptest.NewRecorder()
+		handleRequest(w, req, tt.path, loaded, NewErrorSimulator(0.0))
+		if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+			t.Errorf("%s %s: expected %s, got %s", tt.method, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestValidateLatencySchedule(t *testing.T) {
+	latency := LatencyConfig{Low: 1, High: 2, Schedule: []LatencyScheduleEntry{{From: "9am", To: "10:00"}}}
+	if err := validateLatency("latency", latency); err == nil {
+		t.Error("Expected an error for an invalid schedule time")
+	}
+}
+
+func TestLoadConfigStdin(t *testing.T) {
+	defer func(original io.Reader) { stdin = original }(stdin)
+	stdin = strings.NewReader(validConfig)
+
+	config, err := loadConfig("-")
+	if err != nil {
+		t.Fatalf("Expected config to load from stdin, got error: %v", err)
+	}
+	if config.APISpec.String() != "spec.yaml" {
+		t.Errorf("Expected api_spec from stdin, got %q", config.APISpec)
+	}
+}
+
+func TestLoadConfigJSON5(t *testing.T) {
+	const json5Config = `{
+  // The same settings as validConfig.
+  api_spec: 'spec.yaml',
+  latency: {low: 100, high: 1000,},
+  responses: {
+    "/v1/test": {
+      response: "{\"message\":\"override\"}", /* escaped JSON body */
+    },
+  },
+  error_response: {
+    code: 500,
+    body: {error: "simulated error"},
+    frequency: 0.05,
+  },
+  prefix: "v1",
+}
+`
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "config.yaml")
+	json5File := filepath.Join(dir, "config.json5")
+	if err := os.WriteFile(yamlFile, []byte(validConfig), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if err := os.WriteFile(json5File, []byte(json5Config), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	want, err := loadConfig(yamlFile)
+	if err != nil {
+		t.Fatalf("Expected YAML config to load, got error: %v", err)
+	}
+	got, err := loadConfig(json5File)
+	if err != nil {
+		t.Fatalf("Expected JSON5 config to load, got error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected JSON5 config to match YAML config, got %+v, want %+v", got, want)
+	}
+}
+
+// TestLoadConfigErrorCodesWithoutCode verifies codes or family stand in for a missing code.
+func TestLoadConfigErrorCodesWithoutCode(t *testing.T) {
+	for _, extra := range []string{"family: 5xx", "codes: [502, 503]"} {
+		config := strings.Replace(validConfig, "  code: 500\n", "  "+extra+"\n", 1)
+		filename := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(filename, []byte(config), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		if _, err := loadConfig(filename); err != nil {
+			t.Errorf("%s: expected the config to load without code, got error: %v", extra, err)
+		}
+	}
+}
+
+func TestLoadConfigInvalidErrorFamily(t *testing.T) {
+	for _, extra := range []string{"family: 3xx", "codes: [200]"} {
+		config := strings.Replace(validConfig, "frequency: 0.05", "frequency: 0.05\n  "+extra, 1)
+		filename := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(filename, []byte(config), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		if _, err := loadConfig(filename); err == nil {
+			t.Errorf("%s: expected an error", extra)
+		}
+	}
+}
+
+func TestLoadConfigLatencyOptional(t *testing.T) {
+	withoutLatency := strings.Replace(validConfig, "latency:\n  low: 100\n  high: 1000\n", "latency_optional: true\n", 1)
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(filename, []byte(withoutLatency), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	config, err := loadConfig(filename)
+	if err != nil {
+		t.Fatalf("Expected a config without latency to load, got error: %v", err)
+	}
+
+	config.ErrorResponse.Frequency = 0
+	w := httptest.NewRecorder()
+	start := time.Now()
+	handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, NewErrorSimulator(0.0))
+	if elapsed := time.Since(start); w.Code != 200 || elapsed > 50*time.Millisecond {
+		t.Errorf("Expected an immediate 200, got %d after %v", w.Code, elapsed)
+	}
+
+	// A partial latency block is still rejected.
+	partial := strings.Replace(withoutLatency, "latency_optional: true\n", "latency_optional: true\nlatency:\n  low: 100\n", 1)
+	if err := os.WriteFile(filename, []byte(partial), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if _, err := loadConfig(filename); err == nil || !strings.Contains(err.Error(), "latency.high") {
+		t.Errorf("Expected latency.high to be missing, got: %v", err)
+	}
+}
+
+// TestLoadConfigDropEveryFrame verifies dropping every frame is rejected for streams that
+// would then never end.
+func TestLoadConfigDropEveryFrame(t *testing.T) {
+	tests := []struct {
+		streaming string
+		wantErr   bool
+	}{
+		{"drop_probability: 1", false},
+		{"drop_probability: 1\n  repeat: 3", false},
+		{"drop_probability: 1\n  max_events: 5", true},
+		{"drop_probability: 1\n  repeat: infinite", true},
+		{"drop_probability: 0.9\n  max_events: 5", false},
+	}
+	for _, tt := range tests {
+		config := validConfig + "streaming:\n  " + tt.streaming + "\n"
+		filename := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(filename, []byte(config), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		_, err := loadConfig(filename)
+		if tt.wantErr && (err == nil || !strings.Contains(err.Error(), "streaming.drop_probability")) {
+			t.Errorf("%q: expected a streaming.drop_probability error, got: %v", tt.streaming, err)
+		}
+		if !tt.wantErr && err != nil {
+			t.Errorf("%q: expected the config to load, got error: %v", tt.streaming, err)
+		}
+	}
+}
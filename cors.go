@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// corsMiddleware sets Access-Control-Allow-Origin on responses to requests from allowed origins.
+func corsMiddleware(config *Config) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if origin := r.Header.Get("Origin"); origin != "" {
+				setAllowOrigin(w, origin, config.CORS.AllowedOrigins)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setAllowOrigin writes the allow-origin header if origin is permitted. An empty
+// allowed list permits every origin.
+func setAllowOrigin(w http.ResponseWriter, origin string, allowedOrigins []string) {
+	if len(allowedOrigins) == 0 {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			return
+		}
+		if strings.EqualFold(allowed, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			return
+		}
+	}
+}
+
+// getCORSPolicy resolves the preflight policy for a path. Per-path settings take
+// precedence over the global policy, and methods default to those registered for the path.
+func getCORSPolicy(fullPath string, validMethods map[string]bool, config *Config) CORSPolicy {
+	policy := config.CORS.CORSPolicy
+	if override := getEndpointConfig(http.MethodOptions, fullPath, config).CORS; override != nil {
+		if len(override.AllowedMethods) > 0 {
+			policy.AllowedMethods = override.AllowedMethods
+		}
+		if len(override.AllowedHeaders) > 0 {
+			policy.AllowedHeaders = override.AllowedHeaders
+		}
+	}
+	if len(policy.AllowedMethods) == 0 {
+		policy.AllowedMethods = sortedMethods(validMethods)
+	}
+	return policy
+}
+
+// registerPreflightHandler answers CORS preflight (OPTIONS) requests for a path with 204.
+func registerPreflightHandler(router *mux.Router, fullPath string, validMethods map[string]bool, config *Config) {
+	handlePath(router, fullPath, config, func(w http.ResponseWriter, r *http.Request) {
+		policy := getCORSPolicy(fullPath, validMethods, config)
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+		if len(policy.AllowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+		} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			w.Header().Set("Access-Control-Allow-Headers", requested)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}, http.MethodOptions)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// createCORSTestSpec returns a spec with two paths supporting GET and POST.
+func createCORSTestSpec() *APISpec {
+	return &APISpec{
+		Paths: map[string]map[string]interface{}{
+			"/a": {"get": map[string]interface{}{}, "post": map[string]interface{}{}},
+			"/b": {"get": map[string]interface{}{}, "post": map[string]interface{}{}},
+		},
+	}
+}
+
+// preflight sends a CORS preflight request for path through the router.
+func preflight(router http.Handler, path string) *http.Response {
+	req := httptest.NewRequest(http.MethodOptions, path, nil)
+	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Result()
+}
+
+// TestPreflight_PerPathPolicy verifies per-path policies override the global one.
+func TestPreflight_PerPathPolicy(t *testing.T) {
+	config := createTestConfig()
+	config.CORS = CORSConfig{Enabled: true}
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/b": {CORS: &CORSPolicy{AllowedMethods: []string{"GET"}, AllowedHeaders: []string{"X-Custom"}}},
+	}
+	router := setupRouter(config, createCORSTestSpec())
+
+	resA := preflight(router, "/v1/a")
+	if resA.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", resA.StatusCode)
+	}
+	if got := resA.Header.Get("Access-Control-Allow-Methods"); got != "GET, HEAD, POST" {
+		t.Errorf("Expected /v1/a to allow GET, HEAD, POST, got %q", got)
+	}
+	if got := resA.Header.Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Expected allow-origin *, got %q", got)
+	}
+
+	resB := preflight(router, "/v1/b")
+	if got := resB.Header.Get("Access-Control-Allow-Methods"); got != "GET" {
+		t.Errorf("Expected /v1/b to allow GET, got %q", got)
+	}
+	if got := resB.Header.Get("Access-Control-Allow-Headers"); got != "X-Custom" {
+		t.Errorf("Expected /v1/b to allow X-Custom, got %q", got)
+	}
+}
+
+// TestPreflight_Disabled verifies OPTIONS gets a plain 204 without CORS headers when CORS
+// is disabled.
+func TestPreflight_Disabled(t *testing.T) {
+	config := createTestConfig()
+	router := setupRouter(config, createCORSTestSpec())
+
+	res := preflight(router, "/v1/a")
+	if res.StatusCode != http.StatusNoContent {
+		t.Errorf("Expected status 204 with CORS disabled, got %d", res.StatusCode)
+	}
+	if got := res.Header.Get("Access-Control-Allow-Methods"); got != "" {
+		t.Errorf("Expected no Access-Control-Allow-Methods with CORS disabled, got %q", got)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// pathCounters counts events per key, typically a path. It is safe for concurrent use.
+type pathCounters struct {
+	mu     sync.Mutex
+	counts map[string]*atomic.Uint64
+}
+
+// newPathCounters creates an empty set of counters.
+func newPathCounters() *pathCounters {
+	return &pathCounters{counts: make(map[string]*atomic.Uint64)}
+}
+
+// counter returns the counter for key, creating it on first use.
+func (p *pathCounters) counter(key string) *atomic.Uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.counts[key]
+	if !ok {
+		c = new(atomic.Uint64)
+		p.counts[key] = c
+	}
+	return c
+}
+
+// increment adds one to the counter for key and returns the new count.
+func (p *pathCounters) increment(key string) uint64 {
+	return p.counter(key).Add(1)
+}
+
+// snapshot returns the current count for every key.
+func (p *pathCounters) snapshot() map[string]uint64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	counts := make(map[string]uint64, len(p.counts))
+	for key, c := range p.counts {
+		counts[key] = c.Load()
+	}
+	return counts
+}
+
+// reset zeroes the counter for key, or every counter if key is empty.
+func (p *pathCounters) reset(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k, c := range p.counts {
+		if key == "" || k == key {
+			c.Store(0)
+		}
+	}
+}
+
+// resetPath zeroes the counters for a path, including those keyed "METHOD path" or
+// "METHOD path key", or every counter if path is empty.
+func (p *pathCounters) resetPath(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k, c := range p.counts {
+		if fields := strings.Fields(k); path == "" || k == path || len(fields) > 1 && fields[1] == path {
+			c.Store(0)
+		}
+	}
+}
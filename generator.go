@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// StreamGenerator produces the data frames of a streamed response dynamically, instead of
+// splitting the configured body. It sends each frame's data on the returned channel and
+// closes it when the stream is complete. The generator must stop sending once ctx is
+// done, which happens when the client disconnects or the stream ends.
+type StreamGenerator func(ctx context.Context, r *http.Request) <-chan string
+
+// RegisterStreamGenerator makes streamed responses for a full path (including the
+// prefix, e.g. "/v1/chat") come from generator. Latency between frames, the done marker,
+// and trailers apply as for configured streams.
+func RegisterStreamGenerator(config *Config, path string, generator StreamGenerator) {
+	state := getRuntimeState(config)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.generators[strings.TrimRight(path, "/")] = generator
+}
+
+// streamGenerator returns the generator registered for a path, or nil if there is none.
+func (s *runtimeState) streamGenerator(path string) StreamGenerator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generators[strings.TrimRight(path, "/")]
+}
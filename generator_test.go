@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRegisterStreamGenerator verifies a registered generator's computed chunks are
+// streamed, followed by the done marker.
+func TestRegisterStreamGenerator(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	RegisterStreamGenerator(config, "/v1/test", func(ctx context.Context, r *http.Request) <-chan string {
+		chunks := make(chan string)
+		go func() {
+			defer close(chunks)
+			for i := 1; i <= 3; i++ {
+				select {
+				case chunks <- fmt.Sprintf(`{"square":%d}`, i*i):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return chunks
+	})
+
+	req := httptest.NewRequest("GET", "http://example.com/v1/test?stream=true", nil)
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+
+	want := "data: {\"square\":1}\n\ndata: {\"square\":4}\n\ndata: {\"square\":9}\n\ndata: [DONE]\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("Expected generated stream %q, got %q", want, got)
+	}
+
+	// Other paths keep streaming their configured body.
+	req = httptest.NewRequest("GET", "http://example.com/v1/other?stream=true", nil)
+	w = httptest.NewRecorder()
+	handleRequest(w, req, "/v1/other", config, NewErrorSimulator(0.0))
+	if got := w.Body.String(); got == want {
+		t.Error("Expected /v1/other not to use the generator")
+	}
+}
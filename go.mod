@@ -3,6 +3,7 @@ module mock-api
 go 1.22.0
 
 require (
+	github.com/andybalholm/brotli v1.2.5
 	github.com/gorilla/mux v1.8.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// grpcWebContentType is the content type of binary gRPC-Web responses.
+const grpcWebContentType = "application/grpc-web+proto"
+
+// gRPC-Web frame flags.
+const (
+	grpcWebDataFrame    byte = 0x00
+	grpcWebTrailerFrame byte = 0x80
+)
+
+// grpcWebResponse writes a unary gRPC-Web response: a data frame holding the endpoint's
+// base64 payload followed by a trailer frame carrying grpc-status and grpc-message.
+func grpcWebResponse(w http.ResponseWriter, endpoint EndpointConfig, config *Config) {
+	message, err := base64.StdEncoding.DecodeString(endpoint.BodyBase64)
+	if err != nil {
+		log.Printf("Error decoding base64 body: %v", err)
+		sendJSONError(w, http.StatusInternalServerError, "Internal server error", config)
+		return
+	}
+	trailers := fmt.Sprintf("grpc-status:%d\r\ngrpc-message:%s\r\n", endpoint.GRPCStatus, endpoint.GRPCMessage)
+
+	var body bytes.Buffer
+	writeGRPCWebFrame(&body, grpcWebDataFrame, message)
+	writeGRPCWebFrame(&body, grpcWebTrailerFrame, []byte(trailers))
+
+	w.Header().Set("Content-Type", grpcWebContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	if _, err := w.Write(body.Bytes()); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// writeGRPCWebFrame appends a frame: a flag byte, the big-endian payload length, and the payload.
+func writeGRPCWebFrame(buf *bytes.Buffer, flag byte, payload []byte) {
+	buf.WriteByte(flag)
+	binary.Write(buf, binary.BigEndian, uint32(len(payload)))
+	buf.Write(payload)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"net/http/httptest"
+	"testing"
+)
+
+// readGRPCWebFrame splits the first frame off data, returning its flag and payload.
+func readGRPCWebFrame(t *testing.T, data []byte) (byte, []byte, []byte) {
+	t.Helper()
+	if len(data) < 5 {
+		t.Fatalf("Expected a 5-byte frame header, got %d bytes", len(data))
+	}
+	length := binary.BigEndian.Uint32(data[1:5])
+	if uint32(len(data)-5) < length {
+		t.Fatalf("Expected a %d-byte payload, got %d bytes", length, len(data)-5)
+	}
+	return data[0], data[5 : 5+length], data[5+length:]
+}
+
+func TestHandleRequest_GRPCWeb(t *testing.T) {
+	message := []byte{0x0a, 0x05, 'h', 'e', 'l', 'l', 'o'}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/greeter.Greeter/SayHello": {
+		GRPCWeb:    true,
+		BodyBase64: base64.StdEncoding.EncodeToString(message),
+	}}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "http://example.com/v1/greeter.Greeter/SayHello", nil)
+	handleRequest(w, req, "/v1/greeter.Greeter/SayHello", config, NewErrorSimulator(0.0))
+
+	if got := w.Header().Get("Content-Type"); got != grpcWebContentType {
+		t.Errorf("Expected Content-Type %s, got %s", grpcWebContentType, got)
+	}
+	flag, payload, rest := readGRPCWebFrame(t, w.Body.Bytes())
+	if flag != grpcWebDataFrame || string(payload) != string(message) {
+		t.Errorf("Expected data frame with %x, got flag %x payload %x", message, flag, payload)
+	}
+	flag, payload, rest = readGRPCWebFrame(t, rest)
+	if flag != grpcWebTrailerFrame || string(payload) != "grpc-status:0\r\ngrpc-message:\r\n" {
+		t.Errorf("Expected trailer frame with grpc-status 0, got flag %x payload %q", flag, payload)
+	}
+	if len(rest) != 0 {
+		t.Errorf("Expected no data after the trailers, got %x", rest)
+	}
+}
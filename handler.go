@@ -1,51 +1,337 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"math/rand"
+	"mime"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
-// ErrorResponse represents a standardized error response
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
+// streamChunkCount is the number of SSE data frames a streamed response is split into.
+const streamChunkCount = 3
+
+// defaultStreamParam and defaultStreamValue form the query parameter that requests streaming.
+const (
+	defaultStreamParam = "stream"
+	defaultStreamValue = "true"
+)
+
+// defaultDoneMarker is the content of the frame that terminates a stream.
+const defaultDoneMarker = "[DONE]"
+
+// methodOverrideHeader lets clients tunnel other methods (PUT, DELETE, ...) through POST.
+const methodOverrideHeader = "X-HTTP-Method-Override"
+
+// defaultErrorKey is the JSON key holding the message in error responses.
+const defaultErrorKey = "error"
+
+// defaultJSONContentType is the content type of JSON responses unless json_content_type is set.
+const defaultJSONContentType = "application/json"
 
 // handleRequest simulates latency, random failures, and returns the (possibly overridden)
 // response. It also streams if the query parameter stream=true is present.
 func handleRequest(w http.ResponseWriter, r *http.Request, path string, config *Config, simulator *ErrorSimulator) {
+	state := getRuntimeState(config)
+	clock := state.clock
+	start := clock.Now()
+	metrics := state.metrics
+	// The handling time includes encoding and writing, unlike the chosen latency.
+	var chosenLatency time.Duration
+	defer func() {
+		elapsed := clock.Now().Sub(start)
+		metrics.requestDuration.observe(elapsed)
+		log.Printf("Path %s: Handled in %v (simulated latency %v)", path, elapsed, chosenLatency)
+	}()
+	method := resolveMethod(r, config)
+	requestCount := state.requestCounts.increment(strings.TrimRight(path, "/"))
+
+	if inMaintenance(config, start) {
+		maintenanceResponse(w, config)
+		return
+	}
+
+	if config.ValidateRequests {
+		if name := state.missingQueryParam(method+" "+path, r); name != "" {
+			sendJSONError(w, http.StatusBadRequest, "Missing required query parameter: "+name, config)
+			return
+		}
+		if name := state.invalidPathParam(method+" "+path, r); name != "" {
+			sendJSONError(w, http.StatusBadRequest, "Invalid path parameter: "+name, config)
+			return
+		}
+	}
+	if !acceptsContentType(r, getEndpointConfig(method, path, config).Accepts) {
+		sendJSONError(w, http.StatusUnsupportedMediaType, "Unsupported media type", config)
+		return
+	}
+	if !preconditionMet(r, method, getEndpointConfig(method, path, config).ETag) {
+		sendJSONError(w, http.StatusPreconditionFailed, "Precondition failed", config)
+		return
+	}
+	if !checkBodySize(w, r, method, path, config) {
+		return
+	}
+	if !readSlowly(r, config) {
+		log.Printf("Path %s: Client went away during slow body read", path)
+		return
+	}
+	if !validJSONBody(r, getEndpointConfig(method, path, config).Accepts) {
+		sendJSONError(w, http.StatusBadRequest, "Malformed JSON body", config)
+		return
+	}
+
+	// Decide on a simulated error first, since errors may have their own latency.
+	// Deterministic failures count every request, so they are evaluated unconditionally.
+	failedAttempt := failsAttempt(r, method, path, config)
+	nthError := isNthError(method, path, config)
+	simulateErr := isErrorForced(r, config) || failedAttempt || nthError ||
+		(requestCount > config.ErrorResponse.Warmup && errorsApplyTo(method, path, config) && simulator.ShouldError())
+
 	// Simulate latency.
-	chosenLatency := getLatency(config)
-	log.Printf("Path %s: Sleeping for %f ms", path, chosenLatency)
-	time.Sleep(time.Duration(chosenLatency) * time.Millisecond)
+	latency := getEndpointLatency(method, path, config)
+	if simulateErr && config.ErrorResponse.Latency != nil {
+		latency = *config.ErrorResponse.Latency
+	}
+	latency = latency.at(start)
+	chosenLatency = latencyDuration(latency, sampleLatency(latency))
+	if coldStart := getEndpointConfig(method, path, config).ColdStartMs; coldStart > 0 && requestCount == 1 {
+		log.Printf("Path %s: Cold start adds %dms", path, coldStart)
+		chosenLatency += time.Duration(coldStart) * time.Millisecond
+	}
+	metrics.simulatedLatency.observe(chosenLatency)
+	log.Printf("Path %s: Sleeping for %v", path, chosenLatency)
+	if !waitLatency(r.Context(), clock, chosenLatency) {
+		log.Printf("Path %s: Client went away during latency wait", path)
+		return
+	}
+
+	// Replay the stored response for a repeated idempotency key.
+	if key := getIdempotencyKey(r, method, path, config); key != "" {
+		serveIdempotent(w, key, config, func(w http.ResponseWriter) {
+			respond(w, r, method, path, config, simulateErr)
+		})
+		return
+	}
+	respond(w, r, method, path, config, simulateErr)
+}
 
-	// Possibly simulate an error.
-	if simulator.ShouldError() {
+// preconditionMet reports whether a write request's If-Match header matches the endpoint's
+// ETag. Reads, requests without If-Match, and endpoints without an ETag always pass.
+func preconditionMet(r *http.Request, method, etag string) bool {
+	header := r.Header.Get("If-Match")
+	if etag == "" || header == "" {
+		return true
+	}
+	switch method {
+	case http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return true
+	}
+	want := quoteETag(etag)
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == want {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteETag returns an ETag in its quoted header form.
+func quoteETag(etag string) string {
+	if strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// checkBodySize enforces the endpoint's max_body_bytes, or the global limit, answering
+// larger requests with 413. Accepted bodies are buffered so they can be read again.
+// It reports whether the request may proceed.
+func checkBodySize(w http.ResponseWriter, r *http.Request, method, path string, config *Config) bool {
+	endpoint := getEndpointConfig(method, path, config)
+	limit := config.MaxBodyBytes
+	if endpoint.MaxBodyBytes > 0 {
+		limit = endpoint.MaxBodyBytes
+	}
+	if limit <= 0 || r.Body == nil {
+		return true
+	}
+
+	if r.ContentLength <= limit {
+		data, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+		r.Body.Close()
+		if err != nil {
+			log.Printf("Error reading request body: %v", err)
+			sendJSONError(w, http.StatusBadRequest, "Error reading request body", config)
+			return false
+		}
+		if int64(len(data)) <= limit {
+			r.Body = io.NopCloser(bytes.NewReader(data))
+			return true
+		}
+	}
+
+	log.Printf("Path %s: Rejecting request body over %d bytes", path, limit)
+	if endpoint.TooLargeBody == nil {
+		sendJSONError(w, http.StatusRequestEntityTooLarge, "Request body too large", config)
+		return false
+	}
+	statusResponse(w, http.StatusRequestEntityTooLarge, decodeResponse(endpoint.TooLargeBody, config), config)
+	return false
+}
+
+// inMaintenance reports whether maintenance mode is switched on or now falls within a
+// scheduled maintenance window.
+func inMaintenance(config *Config, now time.Time) bool {
+	if getRuntimeState(config).maintenance.Load() {
+		return true
+	}
+	for _, window := range config.Maintenance.Windows {
+		if window.contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// maintenanceResponse writes the 503 served while in maintenance.
+func maintenanceResponse(w http.ResponseWriter, config *Config) {
+	if config.Maintenance.Body == nil {
+		sendJSONError(w, http.StatusServiceUnavailable, "Service under maintenance", config)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	if err := json.NewEncoder(w).Encode(decodeResponse(config.Maintenance.Body, config)); err != nil {
+		log.Printf("Error encoding maintenance response: %v", err)
+	}
+}
+
+// respond writes either a simulated error or the (possibly overridden) response.
+func respond(w http.ResponseWriter, r *http.Request, method, path string, config *Config, simulateErr bool) {
+	endpoint := getEndpointConfig(method, path, config)
+	if config.StrictAccept && !acceptable(r.Header.Get("Accept"), responseType(r, endpoint, config)) {
+		sendJSONError(w, http.StatusNotAcceptable, "Not acceptable", config)
+		return
+	}
+
+	if simulateErr {
 		simulateError(w, r, config)
 		return
 	}
 
-	responseData := getResponseData(path, config)
-	if isStreaming(r) {
-		streamResponse(w, responseData, config)
+	// The ETag goes with every successful body, whatever its type.
+	if endpoint.ETag != "" {
+		w.Header().Set("ETag", quoteETag(endpoint.ETag))
+	}
+	if endpoint.DelayOnly {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if endpoint.File != "" {
+		fileResponse(w, endpoint, config)
+		return
+	}
+	if endpoint.GRPCWeb {
+		grpcWebResponse(w, endpoint, config)
+		return
+	}
+	if endpoint.BodyBase64 != "" {
+		base64Response(w, endpoint, config)
+		return
+	}
+
+	if override, ok := lookupOverride(method, strings.TrimRight(path, "/"), config); ok {
+		if location, status, redirect := parseRedirectOverride(override); redirect {
+			redirectResponse(w, location, status)
+			return
+		}
+	}
+
+	render := func() interface{} {
+		responseData := getResponseData(r, method, path, config)
+		if config.Templating {
+			responseData = renderTemplates(responseData, newTemplateData(r))
+		}
+		return responseData
+	}
+	var responseData interface{}
+	if ttl := cacheTTL(method, path, config); ttl > 0 {
+		state := getRuntimeState(config)
+		responseData = state.bodies.get(method+" "+strings.TrimRight(path, "/"), state.clock.Now(), ttl, render)
 	} else {
-		normalResponse(w, responseData)
+		responseData = render()
+	}
+	for name, value := range endpoint.Headers {
+		w.Header().Set(name, value)
 	}
+	if endpoint.ContentType != "" && !isStreaming(r, config) {
+		w.Header().Set("Content-Type", endpoint.ContentType)
+	}
+	if isStreaming(r, config) {
+		streamResponse(w, r, path, responseData, config)
+	} else {
+		status := endpoint.Status
+		if override := getStatusOverride(r, config); override != 0 {
+			status = override
+		}
+		statusResponse(w, status, responseData, config)
+	}
+}
+
+// cacheTTL returns how long the rendered body of an endpoint's cache_ttl override is
+// reused, or 0 if it isn't cached.
+func cacheTTL(method, path string, config *Config) time.Duration {
+	override, ok := lookupOverride(method, strings.TrimRight(path, "/"), config)
+	if !ok {
+		return 0
+	}
+	_, ttl, _ := parseCachedOverride(override)
+	return ttl
+}
+
+// getStatusOverride returns the status requested by an X-Mock-Status header, or 0 if there
+// is none. It requires request overrides to be enabled.
+func getStatusOverride(r *http.Request, config *Config) int {
+	header := r.Header.Get("X-Mock-Status")
+	if !config.RequestOverrides || header == "" {
+		return 0
+	}
+	status, err := strconv.Atoi(header)
+	if err != nil || status < 200 || status > 599 {
+		log.Printf("Ignoring invalid X-Mock-Status header: %s", header)
+		return 0
+	}
+	return status
 }
 
-// getLatency selects low or high latency based on the configured frequency.
-func getLatency(config *Config) float64 {
-	return config.Latency.Low + rand.Float64()*(config.Latency.High-config.Latency.Low)
+// getErrorKey returns the configured JSON key for error messages.
+func getErrorKey(config *Config) string {
+	if config == nil || config.ErrorKey == "" {
+		return defaultErrorKey
+	}
+	return config.ErrorKey
 }
 
-func sendJSONError(w http.ResponseWriter, code int, message string) {
+// sendJSONError writes a JSON error envelope with the message under the configured error key.
+func sendJSONError(w http.ResponseWriter, code int, message string, config *Config) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	if err := json.NewEncoder(w).Encode(ErrorResponse{Error: message}); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]string{getErrorKey(config): message}); err != nil {
 		log.Printf("Error encoding error response: %v", err)
 		// If JSON encoding fails, send a minimal JSON error
 		if _, err := w.Write([]byte(`{"error":"Internal server error"}`)); err != nil {
@@ -58,12 +344,21 @@ func sendJSONError(w http.ResponseWriter, code int, message string) {
 // simulateError writes an error response, streaming if requested.
 func simulateError(w http.ResponseWriter, r *http.Request, config *Config) {
 	log.Printf("Simulating error for request")
+	code := errorStatus(config)
+	if rand.Float64() < config.ErrorResponse.ResetProbability[code] {
+		log.Printf("Resetting connection for simulated %d", code)
+		// net/http closes the connection without a response for this panic.
+		panic(http.ErrAbortHandler)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(config.ErrorResponse.Code)
+	errorBody := getErrorBody(r, config)
+	if isStreaming(r, config) {
+		streamError(w, errorBody)
+		return
+	}
 
-	// Convert the error body to a JSON-compatible format
-	errorBody := convertToJSONCompatible(config.ErrorResponse.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
 
 	jsonBytes, err := json.Marshal(errorBody)
 	if err != nil {
@@ -78,30 +373,213 @@ func simulateError(w http.ResponseWriter, r *http.Request, config *Config) {
 	}
 }
 
-// getResponseData returns an override response if present; otherwise, a default message.
-func getResponseData(path string, config *Config) interface{} {
+// resolveMethod returns the effective request method. When method overriding is enabled,
+// a POST carrying the X-HTTP-Method-Override header resolves as the method it names.
+func resolveMethod(r *http.Request, config *Config) string {
+	if config.MethodOverride && r.Method == http.MethodPost {
+		if override := strings.TrimSpace(r.Header.Get(methodOverrideHeader)); override != "" {
+			return strings.ToUpper(override)
+		}
+	}
+	return r.Method
+}
+
+// lookupOverride finds the override for a path, preferring a "METHOD /path" entry
+// over a plain "/path" entry.
+func lookupOverride(method, path string, config *Config) (interface{}, bool) {
+	if override, ok := config.Responses[method+" "+path]; ok {
+		return override, true
+	}
+	override, ok := config.Responses[path]
+	return override, ok
+}
+
+// failsAttempt counts an attempt at a fail_first endpoint and reports whether it is one
+// of the first N, which fail. Requests with an Idempotency-Key header are counted per key.
+func failsAttempt(r *http.Request, method, path string, config *Config) bool {
+	failFirst := getEndpointConfig(method, path, config).FailFirst
+	if failFirst == 0 {
+		return false
+	}
+	key := method + " " + strings.TrimRight(path, "/")
+	if idempotencyKey := r.Header.Get(idempotencyHeader); idempotencyKey != "" {
+		key += " " + idempotencyKey
+	}
+	return getRuntimeState(config).attemptCounts.increment(key) <= failFirst
+}
+
+// isNthError counts a request at an every_nth_error endpoint and reports whether it is
+// the Nth, 2Nth, ... request, which fails.
+func isNthError(method, path string, config *Config) bool {
+	every := getEndpointConfig(method, path, config).EveryNthError
+	if every == 0 {
+		return false
+	}
+	count := getRuntimeState(config).nthErrorCounts.increment(method + " " + strings.TrimRight(path, "/"))
+	return count%every == 0
+}
+
+// errorsApplyTo reports whether random errors are simulated for an endpoint. Endpoints
+// marked no_errors never fail, and an empty error_response.only_methods list applies
+// errors to every method.
+func errorsApplyTo(method, path string, config *Config) bool {
+	if getEndpointConfig(method, path, config).NoErrors {
+		return false
+	}
+	if len(config.ErrorResponse.OnlyMethods) == 0 {
+		return true
+	}
+	for _, allowed := range config.ErrorResponse.OnlyMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// Status families for ErrorResponseConfig.Family, and the codes sampled for each.
+const (
+	errorFamilyClient = "4xx"
+	errorFamilyServer = "5xx"
+)
+
+var errorFamilyCodes = map[string][]int{
+	errorFamilyClient: {
+		http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+		http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests,
+	},
+	errorFamilyServer: {
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout,
+	},
+}
+
+// errorStatus picks the status of a simulated error: one of the configured codes, or of
+// the configured family's codes, at random, falling back to the single configured code.
+func errorStatus(config *Config) int {
+	codes := config.ErrorResponse.Codes
+	if len(codes) == 0 {
+		codes = errorFamilyCodes[config.ErrorResponse.Family]
+	}
+	if len(codes) == 0 {
+		return config.ErrorResponse.Code
+	}
+	return codes[rand.Intn(len(codes))]
+}
+
+// isErrorForced reports whether the request asks for a simulated error via
+// ?force_error=true. It requires request overrides to be enabled.
+func isErrorForced(r *http.Request, config *Config) bool {
+	return config.RequestOverrides && r.URL.Query().Get("force_error") == "true"
+}
+
+// getErrorBody returns the body for a simulated error. When request overrides are enabled,
+// a valid JSON ?error_body= parameter replaces the configured body.
+func getErrorBody(r *http.Request, config *Config) interface{} {
+	if config.RequestOverrides {
+		if raw := r.URL.Query().Get("error_body"); raw != "" {
+			var body interface{}
+			if err := decodeJSON(raw, config, &body); err == nil {
+				return body
+			}
+			log.Printf("Ignoring invalid error_body parameter: %s", raw)
+		}
+	}
+	// A list of bodies is cycled through, one per simulated error.
+	if bodies, ok := config.ErrorResponse.Body.([]interface{}); ok && len(bodies) > 0 {
+		i := getRuntimeState(config).errorBodyIndex.Add(1) - 1
+		return convertToJSONCompatible(bodies[i%uint64(len(bodies))])
+	}
+	// Convert the error body to a JSON-compatible format
+	return convertToJSONCompatible(config.ErrorResponse.Body)
+}
+
+// getResponseData returns the body of the first matching rule or localized variant,
+// then an override response if present; otherwise, a default message.
+func getResponseData(r *http.Request, method, path string, config *Config) interface{} {
 	// Normalize path by trimming trailing slashes
 	normalizedPath := strings.TrimRight(path, "/")
 
-	if override, ok := config.Responses[normalizedPath]; ok {
-		switch v := override.(type) {
-		case string:
-			// If it's a string, try to decode it as JSON into a map
-			var result map[string]interface{}
-			if err := json.Unmarshal([]byte(v), &result); err != nil {
-				log.Printf("Failed to parse JSON string: %v", err)
-				return map[string]string{"error": "Invalid JSON override"}
+	input := newMatchInput(r, getRuntimeState(config).responseCounts.increment(normalizedPath))
+	endpoint := getEndpointConfig(method, normalizedPath, config)
+	if rule, ok := matchRule(input, endpoint.Rules); ok {
+		return decodeResponse(rule.Body, config)
+	}
+	if body, ok := matchLanguage(r, endpoint.Localized); ok {
+		return decodeResponse(body, config)
+	}
+
+	if override, ok := lookupOverride(method, normalizedPath, config); ok {
+		if body, _, cached := parseCachedOverride(override); cached {
+			override = body
+		}
+		if body, hasBody, profiled := parseProfiledOverride(override, config.Profile); profiled {
+			if !hasBody {
+				return defaultResponse(method, normalizedPath, config)
 			}
-			return result
+			override = body
+		}
+		if bodies, weighted := parseWeightedBodies(override); weighted {
+			override = pickWeighted(bodies)
+		}
+		if body, merge := parseMergeOverride(override); merge {
+			return mergeJSON(defaultResponse(method, normalizedPath, config), decodeResponse(body, config))
+		}
+		if body, size, padded := parsePaddedOverride(override); padded {
+			return padJSON(decodeResponse(body, config), size)
+		}
+		if override != nil {
+			return decodeResponse(override, config)
+		}
+		// An explicit null override is resolved per null_response; by default
+		// it falls through to the default message below.
+		switch config.NullResponse {
+		case "empty":
+			return map[string]interface{}{}
+		case "null":
+			return nil
+		}
+	}
+
+	return defaultResponse(method, normalizedPath, config)
+}
 
-		default:
-			// For YAML structures, convert them properly
-			converted := convertToJSONCompatible(override)
-			return converted
+// defaultResponse returns the body served without an override: the spec's response
+// example if it declares one, or a message naming the path.
+func defaultResponse(method, path string, config *Config) interface{} {
+	if example, ok := getRuntimeState(config).example(method + " " + path); ok {
+		return decodeResponse(example, config)
+	}
+	return map[string]interface{}{"message": fmt.Sprintf("Response for %s", path)}
+}
+
+// decodeResponse converts a configured body, either a JSON string or a YAML structure,
+// into JSON-compatible data.
+func decodeResponse(body interface{}, config *Config) interface{} {
+	switch v := body.(type) {
+	case string:
+		// If it's a string, try to decode it as JSON into a map
+		var result map[string]interface{}
+		if err := decodeJSON(v, config, &result); err != nil {
+			log.Printf("Failed to parse JSON string: %v", err)
+			return map[string]string{getErrorKey(config): "Invalid JSON override"}
 		}
+		return result
+
+	default:
+		// For YAML structures, convert them properly
+		return convertToJSONCompatible(body)
 	}
+}
 
-	return map[string]string{"message": fmt.Sprintf("Response for %s", normalizedPath)}
+// decodeJSON decodes a JSON string, keeping numbers as json.Number when configured
+// so large integers are not rounded through float64.
+func decodeJSON(data string, config *Config, v interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	if config.UseJSONNumber {
+		decoder.UseNumber()
+	}
+	return decoder.Decode(v)
 }
 
 // Simplified map conversion
@@ -123,51 +601,384 @@ func convertToJSONCompatible(i interface{}) interface{} {
 	}
 }
 
-func normalResponse(w http.ResponseWriter, responseData interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(responseData); err != nil {
+// jsonContentType returns the configured content type of JSON responses.
+func jsonContentType(config *Config) string {
+	if config.JSONContentType == "" {
+		return defaultJSONContentType
+	}
+	return config.JSONContentType
+}
+
+// normalResponse sends a JSON response with status 200.
+func normalResponse(w http.ResponseWriter, responseData interface{}, config *Config) {
+	statusResponse(w, http.StatusOK, responseData, config)
+}
+
+// statusResponse sends a JSON response with the given status code, or 200 if it is zero.
+// A Content-Type already set on w, e.g. an endpoint's content_type, is kept.
+func statusResponse(w http.ResponseWriter, status int, responseData interface{}, config *Config) {
+	// Encode before writing anything, so a failure can still become a clean 500.
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(responseData); err != nil {
 		log.Printf("Error encoding response: %v", err)
-		sendJSONError(w, http.StatusInternalServerError, "Internal server error")
+		sendJSONError(w, http.StatusInternalServerError, "Internal server error", config)
 		return
 	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", jsonContentType(config))
+	}
+	if status != 0 && status != http.StatusOK {
+		w.WriteHeader(status)
+	}
+	if _, err := w.Write(body.Bytes()); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
 }
 
-func isStreaming(r *http.Request) bool {
-	return r.URL.Query().Get("stream") == "true"
+// rawContentType returns the content type of an endpoint's file or base64 body: the
+// configured content_type, or one derived from the file extension.
+func rawContentType(endpoint EndpointConfig) string {
+	if endpoint.ContentType != "" {
+		return endpoint.ContentType
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(endpoint.File)); endpoint.File != "" && contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
 }
 
-func streamResponse(w http.ResponseWriter, responseData interface{}, config *Config) {
-	w.Header().Set("Content-Type", "text/event-stream")
-	jsonBytes, err := json.Marshal(responseData)
+// base64Response writes the decoded bytes of a base64 body as-is, e.g. a protobuf message,
+// with the endpoint's status and headers.
+func base64Response(w http.ResponseWriter, endpoint EndpointConfig, config *Config) {
+	data, err := base64.StdEncoding.DecodeString(endpoint.BodyBase64)
 	if err != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error decoding base64 body: %v", err)
+		sendJSONError(w, http.StatusInternalServerError, "Internal server error", config)
 		return
 	}
-	// Divide the JSON into approximately 3 chunks.
-	chunkCount := 3
-	chunkSize := len(jsonBytes) / chunkCount
-	if chunkSize == 0 {
-		chunkSize = len(jsonBytes)
+	for name, value := range endpoint.Headers {
+		w.Header().Set(name, value)
+	}
+	contentType := rawContentType(endpoint)
+	if config.SortKeys {
+		data = sortedJSON(data, contentType)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	status := endpoint.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	if _, err := w.Write(data); err != nil {
+		log.Printf("Error writing response: %v", err)
 	}
-	for i := 0; i < len(jsonBytes); i += chunkSize {
-		end := i + chunkSize
-		if end > len(jsonBytes) {
-			end = len(jsonBytes)
+}
+
+// fileResponse copies a file to the response without loading it into memory, with the
+// endpoint's status and headers. The content type is derived from the file extension
+// unless the endpoint sets one.
+func fileResponse(w http.ResponseWriter, endpoint EndpointConfig, config *Config) {
+	file, err := os.Open(endpoint.File)
+	if err != nil {
+		log.Printf("Error opening response file: %v", err)
+		sendJSONError(w, http.StatusInternalServerError, "Internal server error", config)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		log.Printf("Error reading response file: %v", err)
+		sendJSONError(w, http.StatusInternalServerError, "Internal server error", config)
+		return
+	}
+
+	contentType := rawContentType(endpoint)
+	var body io.Reader = file
+	size := info.Size()
+	if config.SortKeys && isJSONMediaType(contentType) {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			log.Printf("Error reading response file: %v", err)
+			sendJSONError(w, http.StatusInternalServerError, "Internal server error", config)
+			return
 		}
-		chunk := jsonBytes[i:end]
-		fmt.Fprintf(w, "data: %s\n\n", chunk)
-		if f, ok := w.(http.Flusher); ok {
-			f.Flush()
+		data = sortedJSON(data, contentType)
+		body, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	for name, value := range endpoint.Headers {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	status := endpoint.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("Error writing response file: %v", err)
+	}
+}
+
+// isJSONMediaType reports whether contentType is application/json or a +json type.
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// sortedJSON re-encodes a raw JSON body with its object keys in sorted order, keeping
+// numbers as written. Other content types, and bodies that don't parse, are returned
+// unchanged.
+func sortedJSON(data []byte, contentType string) []byte {
+	if !isJSONMediaType(contentType) {
+		return data
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		log.Printf("Not sorting keys of invalid JSON body: %v", err)
+		return data
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return data
+	}
+	return encoded
+}
+
+// isStreaming reports whether the request asks for a streamed response, by default
+// with ?stream=true. The parameter name and value are configurable.
+func isStreaming(r *http.Request, config *Config) bool {
+	param, value := config.Streaming.TriggerParam, config.Streaming.TriggerValue
+	if param == "" {
+		param = defaultStreamParam
+	}
+	if value == "" {
+		value = defaultStreamValue
+	}
+	return r.URL.Query().Get(param) == value
+}
+
+// streamChunks returns the data frames of one pass over a streamed body: one per word of
+// streaming.tokens, one per element of an array body with streaming.array_elements, or
+// the JSON split into streamChunkCount.
+func streamChunks(r *http.Request, responseData interface{}, config *Config) ([][]byte, error) {
+	if config.Streaming.Tokens != "" {
+		words := strings.Fields(renderTemplate(config.Streaming.Tokens, newTemplateData(r)))
+		chunks := make([][]byte, len(words))
+		for i, word := range words {
+			encoded, err := json.Marshal(map[string]string{"token": word})
+			if err != nil {
+				return nil, err
+			}
+			chunks[i] = encoded
+		}
+		return chunks, nil
+	}
+	if elements, ok := responseData.([]interface{}); ok && config.Streaming.ArrayElements {
+		chunks := make([][]byte, len(elements))
+		for i, element := range elements {
+			encoded, err := json.Marshal(element)
+			if err != nil {
+				return nil, err
+			}
+			chunks[i] = encoded
+		}
+		return chunks, nil
+	}
+	jsonBytes, err := json.Marshal(responseData)
+	if err != nil {
+		return nil, err
+	}
+	return splitChunks(jsonBytes, streamChunkCount), nil
+}
+
+// splitChunks divides data into count chunks whose sizes differ by at most one byte.
+// With n = len(data), the first n%count chunks hold n/count+1 bytes and the rest hold
+// n/count bytes. If data is shorter than count, each byte becomes its own chunk.
+func splitChunks(data []byte, count int) [][]byte {
+	if count > len(data) {
+		count = len(data)
+	}
+	if count <= 0 {
+		return nil
+	}
+	chunks := make([][]byte, 0, count)
+	size, remainder := len(data)/count, len(data)%count
+	start := 0
+	for i := 0; i < count; i++ {
+		end := start + size
+		if i < remainder {
+			end++
+		}
+		chunks = append(chunks, data[start:end])
+		start = end
+	}
+	return chunks
+}
+
+// streamResponse writes the response as server-sent events, repeating the chunks as
+// configured by streaming.repeat, or the frames of the path's registered generator. It
+// stops early if the client disconnects.
+func streamResponse(w http.ResponseWriter, r *http.Request, path string, responseData interface{}, config *Config) {
+	state := getRuntimeState(config)
+	// Without a Flusher, frames can't reach the client until the handler returns, so the
+	// stream is sent buffered in one go, and an endless one is refused.
+	if !canFlush(w) {
+		if config.Streaming.Repeat == InfiniteRepeat && config.Streaming.MaxEvents == 0 {
+			log.Printf("Path %s: Cannot stream indefinitely to a response writer without Flush", path)
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Path %s: Response writer cannot flush, sending the stream buffered", path)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	trailerNames := declareTrailers(w, config.Streaming.Trailers)
+	events := 0
+	// capped reports whether streaming.max_events frames have been sent.
+	capped := func() bool {
+		return config.Streaming.MaxEvents > 0 && events >= config.Streaming.MaxEvents
+	}
+	if generator := state.streamGenerator(path); generator != nil {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		for chunk := range generator(ctx, r) {
+			if capped() {
+				break
+			}
+			written, ok := writeStreamChunk(w, r, []byte(chunk), config)
+			if !ok {
+				return
+			}
+			if written {
+				events++
+			}
+		}
+	} else {
+		chunks, err := streamChunks(r, responseData, config)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	frames:
+		for i := 0; config.Streaming.Repeat.continues(i); i++ {
+			for _, chunk := range chunks {
+				if capped() {
+					break frames
+				}
+				written, ok := writeStreamChunk(w, r, chunk, config)
+				if !ok {
+					return
+				}
+				if written {
+					events++
+				}
+			}
 		}
-		// Sleep between chunks.
-		chosenLatency := getLatency(config)
-		time.Sleep(time.Duration(chosenLatency) * time.Millisecond)
 	}
 	// Termination marker.
-	fmt.Fprint(w, "data: [DONE]\n\n")
-	if f, ok := w.(http.Flusher); ok {
-		f.Flush()
+	if marker := getDoneMarker(r, config); marker != "" {
+		fmt.Fprintf(w, "data: %s\n\n", marker)
+		flush(w)
+	}
+	for _, name := range trailerNames {
+		w.Header().Set(name, config.Streaming.Trailers[name])
+	}
+}
+
+// writeStreamChunk writes one SSE data frame, unless streaming.drop_probability drops it,
+// and sleeps for the delay between chunks: streaming.jitter if set, or the request latency.
+// A writer that can't flush gets no delay, since the client sees the frames all at once.
+// It reports whether the frame was written, and returns ok false without writing if the
+// client has disconnected.
+func writeStreamChunk(w http.ResponseWriter, r *http.Request, chunk []byte, config *Config) (written, ok bool) {
+	if r.Context().Err() != nil {
+		log.Printf("Client disconnected, stopping stream")
+		return false, false
+	}
+	if rand.Float64() >= config.Streaming.DropProbability {
+		fmt.Fprintf(w, "data: %s\n\n", chunk)
+		flush(w)
+		written = true
+	}
+	if !canFlush(w) {
+		return written, true
+	}
+	// Sleep between chunks.
+	clock := getRuntimeState(config).clock
+	gap := config.Latency.at(clock.Now())
+	if config.Streaming.Jitter != nil {
+		gap = *config.Streaming.Jitter
+	}
+	waitLatency(r.Context(), clock, latencyDuration(gap, sampleLatency(gap)))
+	return written, true
+}
+
+// streamError sends the error body as a single SSE "error" event, so streaming clients
+// receive it the way a failure mid-stream would arrive.
+func streamError(w http.ResponseWriter, errorBody interface{}) {
+	jsonBytes, err := json.Marshal(errorBody)
+	if err != nil {
+		log.Printf("Error encoding error response: %v", err)
+		jsonBytes = []byte(`{"error":"Internal server error"}`)
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	if _, err := fmt.Fprintf(w, "event: error\ndata: %s\n\n", jsonBytes); err != nil {
+		log.Printf("Error writing error event: %v", err)
+	}
+	flush(w)
+}
+
+// canFlush reports whether w, or a response writer it wraps, can flush, so frames reach
+// the client before the handler returns.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		if _, ok := w.(http.Flusher); ok {
+			return true
+		}
+		wrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = wrapper.Unwrap()
+	}
+}
+
+// flush sends what has been written to w so far, if it can flush.
+func flush(w http.ResponseWriter) {
+	if err := http.NewResponseController(w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Error flushing response: %v", err)
+	}
+}
+
+// getDoneMarker returns the content of the terminal stream frame, or "" if it is disabled.
+// When request overrides are enabled, a ?done_marker= parameter replaces the configured marker.
+func getDoneMarker(r *http.Request, config *Config) string {
+	if config.RequestOverrides && r.URL.Query().Has("done_marker") {
+		return r.URL.Query().Get("done_marker")
+	}
+	if config.Streaming.DoneMarker == nil {
+		return defaultDoneMarker
+	}
+	return *config.Streaming.DoneMarker
+}
+
+// declareTrailers announces the configured trailers in the Trailer header, which must
+// happen before the body is written. It returns the configured trailer names in sorted order.
+func declareTrailers(w http.ResponseWriter, trailers map[string]string) []string {
+	names := make([]string, 0, len(trailers))
+	for name := range trailers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		w.Header().Add("Trailer", http.CanonicalHeaderKey(name))
 	}
+	return names
 }
 
 // marshalJSON converts v to a JSON string (or returns "{}" on error).
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -12,7 +19,7 @@ import (
 // createTestConfig initializes a test configuration with default values.
 func createTestConfig() *Config {
 	return &Config{
-		APISpec: "spec.yaml",
+		APISpec: SpecSources{"spec.yaml"},
 		Latency: LatencyConfig{
 			Low:  10,
 			High: 20,
@@ -159,7 +166,7 @@ func TestHandleRequest_UnknownPath(t *testing.T) {
 // TestSendJSONError ensures error responses are properly formatted.
 func TestSendJSONError(t *testing.T) {
 	w := httptest.NewRecorder()
-	sendJSONError(w, http.StatusInternalServerError, "test error")
+	sendJSONError(w, http.StatusInternalServerError, "test error", createTestConfig())
 
 	res := w.Result()
 	if res.StatusCode != http.StatusInternalServerError {
@@ -176,11 +183,11 @@ func TestSendJSONError(t *testing.T) {
 	}
 }
 
-// TestGetLatency ensures latency values fall within the expected range.
-func TestGetLatency(t *testing.T) {
+// TestSampleLatency ensures latency values fall within the expected range.
+func TestSampleLatency(t *testing.T) {
 	config := createTestConfig()
 	for i := 0; i < 100; i++ {
-		latency := getLatency(config)
+		latency := sampleLatency(config.Latency)
 		if latency < config.Latency.Low || latency > config.Latency.High {
 			t.Errorf("Latency %f is out of range [%f, %f]", latency, config.Latency.Low, config.Latency.High)
 		}
@@ -215,3 +222,1148 @@ func deepEqual(a, b interface{}) bool {
 	bJSON, _ := json.Marshal(b)
 	return string(aJSON) == string(bJSON)
 }
+
+// TestHandleRequest_MethodOverride ensures the override header selects the per-method response.
+func TestHandleRequest_MethodOverride(t *testing.T) {
+	config := createTestConfig()
+	config.MethodOverride = true
+	config.Responses["DELETE /v1/test"] = `{"deleted":true}`
+	errorSim := NewErrorSimulator(0.0)
+
+	req := httptest.NewRequest("POST", "http://example.com/v1/test", nil)
+	req.Header.Set("X-HTTP-Method-Override", "DELETE")
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, errorSim)
+
+	var responseData map[string]interface{}
+	if err := json.NewDecoder(w.Result().Body).Decode(&responseData); err != nil {
+		t.Fatalf("Error decoding JSON: %v", err)
+	}
+	if responseData["deleted"] != true {
+		t.Errorf("Expected DELETE override response, got: %v", responseData)
+	}
+
+	// With overriding disabled the header is ignored.
+	config.MethodOverride = false
+	w = httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, errorSim)
+	responseData = nil
+	if err := json.NewDecoder(w.Result().Body).Decode(&responseData); err != nil {
+		t.Fatalf("Error decoding JSON: %v", err)
+	}
+	if responseData["message"] != "override" {
+		t.Errorf("Expected path override response, got: %v", responseData)
+	}
+}
+
+// TestHandleRequest_UseJSONNumber ensures large integer ids survive without becoming floats.
+func TestHandleRequest_UseJSONNumber(t *testing.T) {
+	config := createTestConfig()
+	config.UseJSONNumber = true
+	config.Responses["/v1/test"] = `{"id":12345678901234567890}`
+	errorSim := NewErrorSimulator(0.0)
+
+	req := httptest.NewRequest("GET", "http://example.com/v1/test", nil)
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, errorSim)
+
+	if body := w.Body.String(); !strings.Contains(body, `"id":12345678901234567890`) {
+		t.Errorf("Expected integer id to be preserved, got: %s", body)
+	}
+}
+
+// TestSplitChunks verifies the remainder is spread across the leading chunks.
+func TestSplitChunks(t *testing.T) {
+	tests := []struct {
+		length int
+		count  int
+		want   []int
+	}{
+		{10, 3, []int{4, 3, 3}},
+		{11, 3, []int{4, 4, 3}},
+		{9, 3, []int{3, 3, 3}},
+		{2, 3, []int{1, 1}},
+		{0, 3, nil},
+	}
+
+	for _, tt := range tests {
+		chunks := splitChunks(make([]byte, tt.length), tt.count)
+		var sizes []int
+		for _, chunk := range chunks {
+			sizes = append(sizes, len(chunk))
+		}
+		if !deepEqual(sizes, tt.want) {
+			t.Errorf("splitChunks(%d bytes, %d) sizes = %v, want %v", tt.length, tt.count, sizes, tt.want)
+		}
+	}
+}
+
+// TestHandleRequest_StreamingTrailers verifies configured trailers follow the streamed body.
+func TestHandleRequest_StreamingTrailers(t *testing.T) {
+	config := createTestConfig()
+	config.Streaming.Trailers = map[string]string{"x-stream-status": "complete"}
+	errorSim := NewErrorSimulator(0.0)
+
+	req := httptest.NewRequest("GET", "http://example.com/?stream=true", nil)
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, errorSim)
+	res := w.Result()
+
+	if got := res.Header.Get("Trailer"); got != "X-Stream-Status" {
+		t.Errorf("Expected Trailer header to declare X-Stream-Status, got %q", got)
+	}
+	if got := res.Trailer.Get("X-Stream-Status"); got != "complete" {
+		t.Errorf("Expected trailer X-Stream-Status=complete, got %q", got)
+	}
+}
+
+// TestHandleRequest_ErrorBodyOverride verifies a request-supplied error body is returned.
+func TestHandleRequest_ErrorBodyOverride(t *testing.T) {
+	config := createTestConfig()
+	config.RequestOverrides = true
+	errorSim := NewErrorSimulator(0.0)
+
+	req := httptest.NewRequest("GET", `http://example.com/v1/test?force_error=true&error_body={"custom":"msg"}`, nil)
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, errorSim)
+	res := w.Result()
+
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", res.StatusCode)
+	}
+	var errResp map[string]string
+	if err := json.NewDecoder(res.Body).Decode(&errResp); err != nil {
+		t.Fatalf("Error decoding JSON: %v", err)
+	}
+	if errResp["custom"] != "msg" {
+		t.Errorf("Expected custom error body, got: %v", errResp)
+	}
+}
+
+// TestHandleRequest_ErrorBodyOverrideInvalid verifies invalid JSON falls back to the configured body.
+func TestHandleRequest_ErrorBodyOverrideInvalid(t *testing.T) {
+	config := createTestConfig()
+	config.RequestOverrides = true
+	errorSim := NewErrorSimulator(0.0)
+
+	req := httptest.NewRequest("GET", `http://example.com/v1/test?force_error=true&error_body={not-json`, nil)
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, errorSim)
+
+	var errResp map[string]string
+	if err := json.NewDecoder(w.Result().Body).Decode(&errResp); err != nil {
+		t.Fatalf("Error decoding JSON: %v", err)
+	}
+	if errResp["error"] != "simulated error" {
+		t.Errorf("Expected configured error body, got: %v", errResp)
+	}
+}
+
+// TestHandleRequest_ForceErrorDisabled verifies force_error is ignored without request overrides.
+func TestHandleRequest_ForceErrorDisabled(t *testing.T) {
+	config := createTestConfig()
+	errorSim := NewErrorSimulator(0.0)
+
+	req := httptest.NewRequest("GET", "http://example.com/v1/test?force_error=true", nil)
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, errorSim)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with overrides disabled, got %d", w.Code)
+	}
+}
+
+// TestHandleRequest_StatusHeader verifies X-Mock-Status sets the status only with request overrides.
+func TestHandleRequest_StatusHeader(t *testing.T) {
+	for _, tt := range []struct {
+		overrides bool
+		want      int
+	}{{true, http.StatusTeapot}, {false, http.StatusOK}} {
+		config := createTestConfig()
+		config.RequestOverrides = tt.overrides
+
+		req := httptest.NewRequest("GET", "http://example.com/v1/test", nil)
+		req.Header.Set("X-Mock-Status", "418")
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+
+		if w.Code != tt.want {
+			t.Errorf("request_overrides %v: expected status %d, got %d", tt.overrides, tt.want, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "override") {
+			t.Errorf("request_overrides %v: expected the normal body, got %q", tt.overrides, w.Body.String())
+		}
+	}
+}
+
+// TestHandleRequest_ColdStart verifies only the first request to a path gets cold_start_ms.
+func TestHandleRequest_ColdStart(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/test": {ColdStartMs: 200}}
+
+	durations := make([]time.Duration, 3)
+	for i := range durations {
+		start := time.Now()
+		handleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, NewErrorSimulator(0.0))
+		durations[i] = time.Since(start)
+	}
+	if durations[0] < 200*time.Millisecond {
+		t.Errorf("Expected the first request to take at least 200ms, took %v", durations[0])
+	}
+	for _, d := range durations[1:] {
+		if d > 100*time.Millisecond {
+			t.Errorf("Expected later requests to be fast, took %v", d)
+		}
+	}
+}
+
+// TestHandleRequest_LatencyUnitSeconds verifies latency values are read as seconds with unit s.
+func TestHandleRequest_LatencyUnitSeconds(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 1, High: 1, Unit: "s"}
+	errorSim := NewErrorSimulator(0.0)
+
+	req := httptest.NewRequest("GET", "http://example.com/v1/test", nil)
+	w := httptest.NewRecorder()
+	start := time.Now()
+	handleRequest(w, req, "/v1/test", config, errorSim)
+	elapsed := time.Since(start)
+
+	if elapsed < time.Second || elapsed > 2*time.Second {
+		t.Errorf("Expected about one second of latency, got %v", elapsed)
+	}
+}
+
+// TestHandleRequest_NullOverride verifies null overrides honor the null_response setting.
+func TestHandleRequest_NullOverride(t *testing.T) {
+	tests := []struct {
+		nullResponse string
+		want         string
+	}{
+		{"", `{"message":"Response for /v1/test"}`},
+		{"default", `{"message":"Response for /v1/test"}`},
+		{"empty", `{}`},
+		{"null", `null`},
+	}
+
+	for _, tt := range tests {
+		config := createTestConfig()
+		config.Responses["/v1/test"] = nil
+		config.NullResponse = tt.nullResponse
+		errorSim := NewErrorSimulator(0.0)
+
+		req := httptest.NewRequest("GET", "http://example.com/v1/test", nil)
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, errorSim)
+
+		if got := strings.TrimSpace(w.Body.String()); got != tt.want {
+			t.Errorf("null_response %q: expected %s, got %s", tt.nullResponse, tt.want, got)
+		}
+	}
+}
+
+// TestHandleRequest_StreamingRepeat verifies the chunk sequence is sent once per repetition.
+func TestHandleRequest_StreamingRepeat(t *testing.T) {
+	config := createTestConfig()
+	config.Streaming.Repeat = 2
+	errorSim := NewErrorSimulator(0.0)
+
+	req := httptest.NewRequest("GET", "http://example.com/?stream=true", nil)
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, errorSim)
+
+	var sequence []string
+	for _, chunk := range splitChunks([]byte(`{"message":"override"}`), streamChunkCount) {
+		sequence = append(sequence, "data: "+string(chunk)+"\n\n")
+	}
+	once := strings.Join(sequence, "")
+	want := once + once + "data: [DONE]\n\n"
+	if body := w.Body.String(); body != want {
+		t.Errorf("Expected chunk sequence twice:\n%q\ngot:\n%q", want, body)
+	}
+}
+
+// TestHandleRequest_StreamingDisconnect verifies an infinite stream stops when the client goes away.
+func TestHandleRequest_StreamingDisconnect(t *testing.T) {
+	config := createTestConfig()
+	config.Streaming.Repeat = InfiniteRepeat
+	errorSim := NewErrorSimulator(0.0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "http://example.com/?stream=true", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		handleRequest(w, req, "/v1/test", config, errorSim)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Infinite stream did not stop after the client disconnected")
+	}
+}
+
+// timedRecorder records when each write to the response happens.
+type timedRecorder struct {
+	*httptest.ResponseRecorder
+	writes []time.Time
+}
+
+func (w *timedRecorder) Write(data []byte) (int, error) {
+	w.writes = append(w.writes, time.Now())
+	return w.ResponseRecorder.Write(data)
+}
+
+// TestHandleRequest_StreamingJitter verifies streaming.jitter varies the gaps between
+// chunks while the request latency is fixed.
+func TestHandleRequest_StreamingJitter(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 5, High: 5}
+	config.Streaming.Repeat = 4
+	config.Streaming.Jitter = &LatencyConfig{Low: 0, High: 40}
+
+	w := &timedRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handleRequest(w, httptest.NewRequest("GET", "http://example.com/?stream=true", nil), "/v1/test", config, NewErrorSimulator(0.0))
+
+	var gaps []float64
+	for i := 1; i < len(w.writes); i++ {
+		gaps = append(gaps, float64(w.writes[i].Sub(w.writes[i-1]).Milliseconds()))
+	}
+	if len(gaps) < 10 {
+		t.Fatalf("Expected at least 10 gaps, got %d", len(gaps))
+	}
+	mean := 0.0
+	for _, gap := range gaps {
+		mean += gap / float64(len(gaps))
+	}
+	variance := 0.0
+	for _, gap := range gaps {
+		variance += (gap - mean) * (gap - mean) / float64(len(gaps))
+	}
+	if variance < 10 {
+		t.Errorf("Expected jittered gaps to vary, got variance %.1fms² over %v", variance, gaps)
+	}
+}
+
+// TestHandleRequest_StreamingDrop verifies frames are dropped at streaming.drop_probability.
+func TestHandleRequest_StreamingDrop(t *testing.T) {
+	for _, tt := range []struct {
+		probability float64
+		frames      int
+	}{{1.0, 0}, {0.0, streamChunkCount}} {
+		config := createTestConfig()
+		config.Streaming.DropProbability = tt.probability
+
+		req := httptest.NewRequest("GET", "http://example.com/?stream=true", nil)
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+
+		body := w.Body.String()
+		if !strings.HasSuffix(body, "data: [DONE]\n\n") {
+			t.Errorf("drop_probability %v: expected the done marker, got %q", tt.probability, body)
+		}
+		if frames := strings.Count(body, "data: ") - 1; frames != tt.frames {
+			t.Errorf("drop_probability %v: expected %d data frames, got %d in %q", tt.probability, tt.frames, frames, body)
+		}
+	}
+}
+
+// TestHandleRequest_StreamingMaxEvents verifies an array body is streamed per element, cut
+// off after max_events frames were written.
+func TestHandleRequest_StreamingMaxEvents(t *testing.T) {
+	config := createTestConfig()
+	config.Responses = map[string]interface{}{"/v1/test": []interface{}{
+		map[interface{}]interface{}{"id": 1}, map[interface{}]interface{}{"id": 2}, map[interface{}]interface{}{"id": 3},
+		map[interface{}]interface{}{"id": 4}, map[interface{}]interface{}{"id": 5},
+	}}
+	config.Streaming.ArrayElements = true
+	config.Streaming.MaxEvents = 3
+
+	req := httptest.NewRequest("GET", "http://example.com/?stream=true", nil)
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+
+	want := "data: {\"id\":1}\n\ndata: {\"id\":2}\n\ndata: {\"id\":3}\n\ndata: [DONE]\n\n"
+	if body := w.Body.String(); body != want {
+		t.Errorf("Expected three element frames then [DONE]:\n%q\ngot:\n%q", want, body)
+	}
+	// Dropped frames don't count toward the limit.
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Streaming.Repeat = InfiniteRepeat
+	config.Streaming.DropProbability = 0.5
+	w = httptest.NewRecorder()
+	handleRequest(w, httptest.NewRequest("GET", "http://example.com/?stream=true", nil), "/v1/test", config, NewErrorSimulator(0.0))
+	if frames := strings.Count(w.Body.String(), "data: {"); frames != 3 {
+		t.Errorf("Expected max_events to count only written frames, got %d in %q", frames, w.Body.String())
+	}
+}
+
+// TestHandleRequest_StreamingTokens verifies streaming.tokens echoes the request body.
+func TestHandleRequest_StreamingTokens(t *testing.T) {
+	config := createTestConfig()
+	config.Streaming.Tokens = "You said: {{.Body.prompt}}"
+
+	req := httptest.NewRequest("POST", "http://example.com/?stream=true", strings.NewReader(`{"prompt": "hello mock"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+
+	want := "data: {\"token\":\"You\"}\n\ndata: {\"token\":\"said:\"}\n\n" +
+		"data: {\"token\":\"hello\"}\n\ndata: {\"token\":\"mock\"}\n\ndata: [DONE]\n\n"
+	if body := w.Body.String(); body != want {
+		t.Errorf("Expected the prompt echoed as tokens:\n%q\ngot:\n%q", want, body)
+	}
+}
+
+// nonFlushingWriter hides the Flush method of the writer it wraps.
+type nonFlushingWriter struct {
+	http.ResponseWriter
+}
+
+// TestHandleRequest_StreamingWithoutFlusher verifies a writer without Flush gets the whole
+// stream at once, also behind middleware, and an infinite stream is refused unless
+// max_events caps it.
+func TestHandleRequest_StreamingWithoutFlusher(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 50, High: 50}
+	config.Streaming.Repeat = 2
+
+	recorder := httptest.NewRecorder()
+	start := time.Now()
+	handleRequest(nonFlushingWriter{recorder}, httptest.NewRequest("GET", "http://example.com/?stream=true", nil), "/v1/test", config, NewErrorSimulator(0.0))
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("Expected no delays between buffered frames, took %v", elapsed)
+	}
+	if frames := strings.Count(recorder.Body.String(), "data: "); frames != 2*streamChunkCount+1 {
+		t.Errorf("Expected %d frames, got %d in %q", 2*streamChunkCount+1, frames, recorder.Body.String())
+	}
+
+	config.Streaming.Repeat = InfiniteRepeat
+	recorder = httptest.NewRecorder()
+	handleRequest(nonFlushingWriter{recorder}, httptest.NewRequest("GET", "http://example.com/?stream=true", nil), "/v1/test", config, NewErrorSimulator(0.0))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("Expected an infinite stream without Flush to get 500, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	wrapped := &compressWriter{ResponseWriter: &statusWriter{ResponseWriter: nonFlushingWriter{recorder}}, encoding: encodingGzip}
+	handleRequest(wrapped, httptest.NewRequest("GET", "http://example.com/?stream=true", nil), "/v1/test", config, NewErrorSimulator(0.0))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("Expected an infinite stream behind middleware without Flush to get 500, got %d", recorder.Code)
+	}
+
+	config.Streaming.MaxEvents = 3
+	recorder = httptest.NewRecorder()
+	handleRequest(nonFlushingWriter{recorder}, httptest.NewRequest("GET", "http://example.com/?stream=true", nil), "/v1/test", config, NewErrorSimulator(0.0))
+	if frames := strings.Count(recorder.Body.String(), "data: "); recorder.Code != http.StatusOK || frames != 4 {
+		t.Errorf("Expected an infinite stream capped by max_events to send 4 frames, got %d with %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+// TestHandleRequest_StreamingDoneMarker verifies custom and disabled terminal frames.
+func TestHandleRequest_StreamingDoneMarker(t *testing.T) {
+	custom, disabled := "END", ""
+	tests := []struct {
+		name   string
+		marker *string
+		want   string
+	}{
+		{"default", nil, "data: [DONE]\n\n"},
+		{"custom", &custom, "data: END\n\n"},
+		{"disabled", &disabled, "data: rride\"}\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := createTestConfig()
+			config.Streaming.DoneMarker = tt.marker
+			errorSim := NewErrorSimulator(0.0)
+
+			req := httptest.NewRequest("GET", "http://example.com/?stream=true", nil)
+			w := httptest.NewRecorder()
+			handleRequest(w, req, "/v1/test", config, errorSim)
+
+			body := w.Body.String()
+			if !strings.HasSuffix(body, tt.want) {
+				t.Errorf("Expected stream to end with %q, got %q", tt.want, body)
+			}
+			if tt.marker != nil && strings.Contains(body, "[DONE]") {
+				t.Errorf("Expected no [DONE] frame, got %q", body)
+			}
+		})
+	}
+}
+
+// TestHandleRequest_DoneMarkerOverride verifies ?done_marker= replaces the terminal frame
+// only when request overrides are enabled.
+func TestHandleRequest_DoneMarkerOverride(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	stream := func() string {
+		req := httptest.NewRequest("GET", "http://example.com/?stream=true&done_marker=END", nil)
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+		return w.Body.String()
+	}
+
+	if body := stream(); !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Errorf("Expected the override to be ignored when disabled, got %q", body)
+	}
+	config.RequestOverrides = true
+	body := stream()
+	if !strings.HasSuffix(body, "data: END\n\n") || strings.Contains(body, "[DONE]") {
+		t.Errorf("Expected END to replace [DONE], got %q", body)
+	}
+}
+
+// TestNormalResponse_Unencodable verifies a body that can't be encoded yields a clean 500
+// with the error envelope and nothing of the failed body.
+func TestNormalResponse_Unencodable(t *testing.T) {
+	config := createTestConfig()
+	w := httptest.NewRecorder()
+	normalResponse(w, map[string]interface{}{"ok": true, "broken": make(chan int)}, config)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type application/json, got %s", ct)
+	}
+	if got := w.Body.String(); got != "{\"error\":\"Internal server error\"}\n" {
+		t.Errorf("Expected only the error envelope, got %q", got)
+	}
+}
+
+// TestSendJSONError_CustomKey ensures the configured error key replaces "error".
+func TestSendJSONError_CustomKey(t *testing.T) {
+	config := createTestConfig()
+	config.ErrorKey = "detail"
+	w := httptest.NewRecorder()
+	sendJSONError(w, http.StatusNotFound, "Not found", config)
+
+	var responseData map[string]string
+	if err := json.NewDecoder(w.Result().Body).Decode(&responseData); err != nil {
+		t.Fatalf("Error decoding JSON: %v", err)
+	}
+	if responseData["detail"] != "Not found" {
+		t.Errorf("Expected message under \"detail\", got: %v", responseData)
+	}
+	if _, ok := responseData["error"]; ok {
+		t.Errorf("Expected no \"error\" key, got: %v", responseData)
+	}
+}
+
+// discardResponseWriter is a ResponseWriter that counts and discards the body.
+type discardResponseWriter struct {
+	header http.Header
+	status int
+	n      int64
+}
+
+func (d *discardResponseWriter) Header() http.Header { return d.header }
+
+func (d *discardResponseWriter) WriteHeader(status int) { d.status = status }
+
+func (d *discardResponseWriter) Write(b []byte) (int, error) {
+	d.n += int64(len(b))
+	return len(b), nil
+}
+
+// TestHandleRequest_FileResponse verifies a large file is served with the correct length
+// without being read into memory.
+func TestHandleRequest_FileResponse(t *testing.T) {
+	const size = 8 << 20
+	filename := t.TempDir() + "/large.bin"
+	if err := os.WriteFile(filename, make([]byte, size), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/download": {File: filename}}
+	errorSim := NewErrorSimulator(0.0)
+	req := httptest.NewRequest("GET", "http://example.com/v1/download", nil)
+
+	w := &discardResponseWriter{header: http.Header{}}
+	handleRequest(w, req, "/v1/download", config, errorSim)
+	if w.n != size {
+		t.Fatalf("Expected %d bytes, got %d", size, w.n)
+	}
+	if got := w.header.Get("Content-Length"); got != strconv.Itoa(size) {
+		t.Errorf("Expected Content-Length %d, got %s", size, got)
+	}
+	if got := w.header.Get("Content-Type"); got != "application/octet-stream" {
+		t.Errorf("Expected Content-Type application/octet-stream, got %s", got)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	allocs := testing.AllocsPerRun(5, func() {
+		handleRequest(&discardResponseWriter{header: http.Header{}}, req, "/v1/download", config, errorSim)
+	})
+	runtime.ReadMemStats(&after)
+	if allocs > 200 {
+		t.Errorf("Expected a bounded number of allocations, got %v per request", allocs)
+	}
+	if perRun := (after.TotalAlloc - before.TotalAlloc) / 6; perRun > size/8 {
+		t.Errorf("Expected the file not to be buffered, allocated %d bytes per request", perRun)
+	}
+}
+
+// TestHandleRequest_ErrorBodyList verifies consecutive errors cycle through a list of bodies.
+func TestHandleRequest_ErrorBodyList(t *testing.T) {
+	config := createTestConfig()
+	config.ErrorResponse.Body = []interface{}{
+		map[interface{}]interface{}{"error": "transient", "trace_id": "a"},
+		map[interface{}]interface{}{"error": "permanent", "trace_id": "b"},
+	}
+	errorSim := NewErrorSimulator(1.0)
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "http://example.com/v1/test", nil)
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, errorSim)
+
+		var errResp map[string]string
+		if err := json.NewDecoder(w.Result().Body).Decode(&errResp); err != nil {
+			t.Fatalf("Error decoding JSON: %v", err)
+		}
+		got = append(got, errResp["error"])
+	}
+
+	want := []string{"transient", "permanent", "transient"}
+	if !deepEqual(got, want) {
+		t.Errorf("Expected error bodies %v, got %v", want, got)
+	}
+}
+
+// TestIsStreaming_CustomTrigger verifies a configured trigger parameter replaces the default.
+func TestIsStreaming_CustomTrigger(t *testing.T) {
+	config := createTestConfig()
+	config.Streaming.TriggerParam = "sse"
+	config.Streaming.TriggerValue = "1"
+
+	custom := httptest.NewRequest("GET", "http://example.com/?sse=1", nil)
+	if !isStreaming(custom, config) {
+		t.Error("Expected ?sse=1 to activate streaming")
+	}
+	standard := httptest.NewRequest("GET", "http://example.com/?stream=true", nil)
+	if isStreaming(standard, config) {
+		t.Error("Expected ?stream=true to be ignored when the trigger is overridden")
+	}
+	if !isStreaming(standard, createTestConfig()) {
+		t.Error("Expected ?stream=true to activate streaming by default")
+	}
+}
+
+// TestHandleRequest_ErrorOnlyMethods verifies random errors only hit the listed methods.
+func TestHandleRequest_ErrorOnlyMethods(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.ErrorResponse.OnlyMethods = []string{"POST", "PUT", "DELETE"}
+	errorSim := NewErrorSimulator(1.0)
+
+	for i := 0; i < 20; i++ {
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, errorSim)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected GET to succeed, got %d", w.Code)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("POST", "http://example.com/v1/test", nil), "/v1/test", config, errorSim)
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected POST to fail, got %d", w.Code)
+		}
+	}
+}
+
+// TestHandleRequest_MaintenanceWindow verifies scheduled windows return the maintenance body.
+func TestHandleRequest_MaintenanceWindow(t *testing.T) {
+	config := createTestConfig()
+	now := time.Now()
+	config.Maintenance = MaintenanceConfig{
+		Windows: []TimeWindow{{Start: now.Add(-time.Minute), End: now.Add(time.Minute)}},
+		Body:    map[string]interface{}{"status": "down for maintenance"},
+	}
+
+	w := httptest.NewRecorder()
+	handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, NewErrorSimulator(0.0))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 during the window, got %d", w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != `{"status":"down for maintenance"}` {
+		t.Errorf("Expected maintenance body, got %s", body)
+	}
+
+	if inMaintenance(config, now.Add(2*time.Minute)) {
+		t.Error("Expected no maintenance after the window ends")
+	}
+}
+
+// TestHandleRequest_StreamingError verifies streaming requests receive errors as an SSE event.
+func TestHandleRequest_StreamingError(t *testing.T) {
+	config := createTestConfig()
+	errorSim := NewErrorSimulator(1.0)
+
+	req := httptest.NewRequest("GET", "http://example.com/?stream=true", nil)
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/test", config, errorSim)
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %s", ct)
+	}
+	want := "event: error\ndata: {\"error\":\"simulated error\"}\n\n"
+	if body := w.Body.String(); body != want {
+		t.Errorf("Expected SSE error event %q, got %q", want, body)
+	}
+}
+
+// TestHandleRequest_ErrorLatency verifies simulated errors use error_response.latency.
+func TestHandleRequest_ErrorLatency(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.ErrorResponse.Latency = &LatencyConfig{Low: 200, High: 200}
+
+	timeRequest := func(simulator *ErrorSimulator) (time.Duration, int) {
+		req := httptest.NewRequest("GET", "http://example.com/v1/test", nil)
+		w := httptest.NewRecorder()
+		start := time.Now()
+		handleRequest(w, req, "/v1/test", config, simulator)
+		return time.Since(start), w.Code
+	}
+
+	success, code := timeRequest(NewErrorSimulator(0.0))
+	if code != http.StatusOK || success > 100*time.Millisecond {
+		t.Errorf("Expected a fast success, got status %d after %v", code, success)
+	}
+	failure, code := timeRequest(NewErrorSimulator(1.0))
+	if code != http.StatusInternalServerError || failure < 200*time.Millisecond {
+		t.Errorf("Expected a slow error, got status %d after %v", code, failure)
+	}
+}
+
+// TestHandleRequest_ResetProbability verifies forced 503s with a reset probability of 1
+// drop the connection, while the client still sees other statuses.
+func TestHandleRequest_ResetProbability(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.RequestOverrides = true
+	config.ErrorResponse.Code = http.StatusServiceUnavailable
+	config.ErrorResponse.ResetProbability = map[int]float64{http.StatusServiceUnavailable: 1.0}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	server := httptest.NewServer(setupRouter(config, spec))
+	defer server.Close()
+
+	if res, err := http.Get(server.URL + "/v1/test?force_error=true"); err == nil {
+		res.Body.Close()
+		t.Fatalf("Expected a connection error, got status %d", res.StatusCode)
+	}
+	res, err := http.Get(server.URL + "/v1/test")
+	if err != nil {
+		t.Fatalf("Expected a normal request to succeed, got error: %v", err)
+	}
+	res.Body.Close()
+
+	config.ErrorResponse.Code = http.StatusInternalServerError
+	res, err = http.Get(server.URL + "/v1/test?force_error=true")
+	if err != nil {
+		t.Fatalf("Expected a 500 to be sent, got error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", res.StatusCode)
+	}
+}
+
+// TestHandleRequest_ErrorFamily verifies a 4xx family only ever produces client errors,
+// and explicit codes only those codes.
+func TestHandleRequest_ErrorFamily(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.ErrorResponse.Family = errorFamilyClient
+	errorSim := NewErrorSimulator(1.0)
+
+	seen := make(map[int]bool)
+	for i := 0; i < 200; i++ {
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, errorSim)
+		if w.Code < 400 || w.Code >= 500 {
+			t.Fatalf("Expected only 4xx errors, got %d", w.Code)
+		}
+		seen[w.Code] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("Expected several 4xx codes to be sampled, got %v", seen)
+	}
+
+	config.ErrorResponse.Codes = []int{http.StatusBadGateway}
+	w := httptest.NewRecorder()
+	handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, errorSim)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected explicit codes to win over the family, got %d", w.Code)
+	}
+}
+
+// TestHandleRequest_DelayOnly verifies delay_only endpoints wait out the latency and send
+// an empty 200.
+func TestHandleRequest_DelayOnly(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	delay := LatencyConfig{Low: 100, High: 100}
+	config.Endpoints = map[string]EndpointConfig{"/v1/slow": {DelayOnly: true, Latency: &delay}}
+
+	w := httptest.NewRecorder()
+	start := time.Now()
+	handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/slow", nil), "/v1/slow", config, NewErrorSimulator(0.0))
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected an empty body, got %q", w.Body.String())
+	}
+	if elapsed < 100*time.Millisecond {
+		t.Errorf("Expected a delay of at least 100ms, got %v", elapsed)
+	}
+}
+
+// TestHandleRequest_NoErrors verifies endpoints marked no_errors never fail.
+func TestHandleRequest_NoErrors(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/health": {NoErrors: true}}
+	errorSim := NewErrorSimulator(1.0)
+
+	for i := 0; i < 20; i++ {
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/health", nil), "/v1/health", config, errorSim)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected /v1/health to succeed, got %d", w.Code)
+		}
+		w = httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, errorSim)
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("Expected /v1/test to fail, got %d", w.Code)
+		}
+	}
+}
+
+// TestHandleRequest_SortedKeys verifies response object keys are sorted by default, so
+// output is reproducible without setting sort_keys.
+func TestHandleRequest_SortedKeys(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses["/v1/test"] = map[interface{}]interface{}{
+		"zeta":  1,
+		"alpha": map[interface{}]interface{}{"y": true, "b": true},
+		"mid":   []interface{}{map[interface{}]interface{}{"k2": 0, "k1": 0}},
+	}
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, NewErrorSimulator(0.0))
+		want := `{"alpha":{"b":true,"y":true},"mid":[{"k1":0,"k2":0}],"zeta":1}`
+		if got := strings.TrimSpace(w.Body.String()); got != want {
+			t.Fatalf("Expected sorted keys %s, got %s", want, got)
+		}
+	}
+}
+
+// TestHandleRequest_Base64Body verifies base64 bodies are served as raw bytes.
+func TestHandleRequest_Base64Body(t *testing.T) {
+	// A protobuf message with field 1 = 150 and field 2 = "hi".
+	payload := []byte{0x08, 0x96, 0x01, 0x12, 0x02, 'h', 'i'}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/message": {
+		BodyBase64:  base64.StdEncoding.EncodeToString(payload),
+		ContentType: "application/x-protobuf",
+	}}
+
+	w := httptest.NewRecorder()
+	handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/message", nil), "/v1/message", config, NewErrorSimulator(0.0))
+
+	if got := w.Header().Get("Content-Type"); got != "application/x-protobuf" {
+		t.Errorf("Expected Content-Type application/x-protobuf, got %s", got)
+	}
+	if !bytes.Equal(w.Body.Bytes(), payload) {
+		t.Errorf("Expected payload %x, got %x", payload, w.Body.Bytes())
+	}
+}
+
+// TestHandleRequest_MaxBodyBytes verifies per-endpoint body limits override the global one.
+func TestHandleRequest_MaxBodyBytes(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.MaxBodyBytes = 100
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/avatar": {MaxBodyBytes: 10, TooLargeBody: `{"error":"avatar too large"}`},
+	}
+	body := strings.Repeat("x", 50)
+
+	tests := []struct {
+		path     string
+		wantCode int
+		wantBody string
+	}{
+		{"/v1/test", http.StatusOK, `{"message":"override"}`},
+		{"/v1/avatar", http.StatusRequestEntityTooLarge, `{"error":"avatar too large"}`},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "http://example.com"+tt.path, strings.NewReader(body))
+		handleRequest(w, req, tt.path, config, NewErrorSimulator(0.0))
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: expected status %d, got %d", tt.path, tt.wantCode, w.Code)
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+			t.Errorf("%s: expected body %s, got %s", tt.path, tt.wantBody, got)
+		}
+	}
+
+	// Bodies of unknown length are limited as they are read.
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "http://example.com/v1/test", strings.NewReader(strings.Repeat("x", 101)))
+	req.ContentLength = -1
+	handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413 over the global limit, got %d", w.Code)
+	}
+}
+
+// TestHandleRequest_FailFirst verifies the first N attempts fail and later ones succeed.
+func TestHandleRequest_FailFirst(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/test": {FailFirst: 2}}
+
+	attempt := func(idempotencyKey string) int {
+		req := httptest.NewRequest("POST", "http://example.com/v1/test", nil)
+		if idempotencyKey != "" {
+			req.Header.Set(idempotencyHeader, idempotencyKey)
+		}
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+		return w.Code
+	}
+
+	for i, want := range []int{500, 500, 200, 200} {
+		if got := attempt(""); got != want {
+			t.Errorf("Attempt %d: expected status %d, got %d", i+1, want, got)
+		}
+	}
+	// Each idempotency key gets its own first failures.
+	for i, want := range []int{500, 500, 200} {
+		if got := attempt("order-1"); got != want {
+			t.Errorf("Keyed attempt %d: expected status %d, got %d", i+1, want, got)
+		}
+	}
+}
+
+// TestHandleRequest_IfMatch verifies writes with a stale If-Match header get 412.
+func TestHandleRequest_IfMatch(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/test": {ETag: "v2"}}
+
+	tests := []struct {
+		method  string
+		ifMatch string
+		want    int
+	}{
+		{"PUT", `"v2"`, http.StatusOK},
+		{"PATCH", `"v1", "v2"`, http.StatusOK},
+		{"PUT", "*", http.StatusOK},
+		{"PUT", `"v1"`, http.StatusPreconditionFailed},
+		{"DELETE", `"v1"`, http.StatusPreconditionFailed},
+		{"GET", `"v1"`, http.StatusOK},
+		{"PUT", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "http://example.com/v1/test", nil)
+		if tt.ifMatch != "" {
+			req.Header.Set("If-Match", tt.ifMatch)
+		}
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+		if w.Code != tt.want {
+			t.Errorf("%s If-Match %s: expected status %d, got %d", tt.method, tt.ifMatch, tt.want, w.Code)
+		}
+		if w.Code == http.StatusOK && w.Header().Get("ETag") != `"v2"` {
+			t.Errorf("%s: expected ETag \"v2\", got %q", tt.method, w.Header().Get("ETag"))
+		}
+	}
+}
+
+// TestHandleRequest_ETagRawBodies verifies the ETag is sent with file, base64, and gRPC-Web
+// bodies as well as JSON ones, and If-Match is checked against it.
+func TestHandleRequest_ETagRawBodies(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(file, []byte("report"), 0644); err != nil {
+		t.Fatalf("Failed to write response file: %v", err)
+	}
+	message := base64.StdEncoding.EncodeToString([]byte("message"))
+	for name, endpoint := range map[string]EndpointConfig{
+		"file":     {File: file},
+		"base64":   {BodyBase64: message},
+		"grpc_web": {BodyBase64: message, GRPCWeb: true},
+	} {
+		config := createTestConfig()
+		config.Latency = LatencyConfig{Low: 0, High: 0}
+		endpoint.ETag = "v2"
+		config.Endpoints = map[string]EndpointConfig{"/v1/test": endpoint}
+
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, NewErrorSimulator(0.0))
+		if got := w.Header().Get("ETag"); got != `"v2"` {
+			t.Errorf("%s: expected ETag \"v2\", got %q", name, got)
+		}
+
+		req := httptest.NewRequest("PUT", "http://example.com/v1/test", nil)
+		req.Header.Set("If-Match", `"v1"`)
+		w = httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+		if w.Code != http.StatusPreconditionFailed {
+			t.Errorf("%s: expected a stale If-Match to get 412, got %d", name, w.Code)
+		}
+	}
+}
+
+// TestHandleRequest_EveryNthError verifies exactly every Nth request fails.
+func TestHandleRequest_EveryNthError(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/test": {EveryNthError: 3}}
+
+	for i := 1; i <= 9; i++ {
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, NewErrorSimulator(0.0))
+		want := http.StatusOK
+		if i%3 == 0 {
+			want = http.StatusInternalServerError
+		}
+		if w.Code != want {
+			t.Errorf("Request %d: expected status %d, got %d", i, want, w.Code)
+		}
+	}
+}
+
+// TestHandleRequest_JSONContentType verifies the configured JSON content types are sent verbatim.
+func TestHandleRequest_JSONContentType(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.JSONContentType = "application/json; charset=utf-8"
+	config.Endpoints = map[string]EndpointConfig{"/v1/resources": {ContentType: "application/vnd.api+json"}}
+
+	tests := map[string]string{
+		"/v1/test":      "application/json; charset=utf-8",
+		"/v1/resources": "application/vnd.api+json",
+	}
+	for path, want := range tests {
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com"+path, nil), path, config, NewErrorSimulator(0.0))
+		if got := w.Header().Get("Content-Type"); got != want {
+			t.Errorf("%s: expected Content-Type %q, got %q", path, want, got)
+		}
+	}
+}
+
+// TestHandleRequest_ErrorWarmup verifies random errors only start after the warmup requests.
+func TestHandleRequest_ErrorWarmup(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.ErrorResponse.Warmup = 5
+	errorSim := NewErrorSimulator(1.0)
+
+	for i := 1; i <= 8; i++ {
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, errorSim)
+		want := http.StatusOK
+		if i > 5 {
+			want = http.StatusInternalServerError
+		}
+		if w.Code != want {
+			t.Errorf("Request %d: expected status %d, got %d", i, want, w.Code)
+		}
+	}
+}
+
+// TestHandleRequest_SortKeysRawBodies verifies JSON files and base64 bodies keep their
+// bytes by default and get sorted keys with sort_keys, while other types are untouched.
+func TestHandleRequest_SortKeysRawBodies(t *testing.T) {
+	raw := `{"zeta": 1, "alpha": [{"y": 12345678901234567890, "b": null}]}`
+	filename := filepath.Join(t.TempDir(), "recorded.json")
+	if err := os.WriteFile(filename, []byte(raw), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	sorted := `{"alpha":[{"b":null,"y":12345678901234567890}],"zeta":1}`
+
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/file":   {File: filename},
+		"/v1/inline": {BodyBase64: base64.StdEncoding.EncodeToString([]byte(raw)), ContentType: "application/problem+json"},
+		"/v1/text":   {BodyBase64: base64.StdEncoding.EncodeToString([]byte(raw)), ContentType: "text/plain"},
+	}
+
+	tests := []struct {
+		sortKeys bool
+		path     string
+		want     string
+	}{
+		{false, "/v1/file", raw},
+		{false, "/v1/inline", raw},
+		{true, "/v1/file", sorted},
+		{true, "/v1/inline", sorted},
+		{true, "/v1/text", raw},
+	}
+	for _, tt := range tests {
+		config.SortKeys = tt.sortKeys
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com"+tt.path, nil), tt.path, config, NewErrorSimulator(0.0))
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("sort_keys %v, %s: expected %s, got %s", tt.sortKeys, tt.path, tt.want, got)
+		}
+		if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(tt.want)) {
+			t.Errorf("sort_keys %v, %s: expected Content-Length %d, got %s", tt.sortKeys, tt.path, len(tt.want), got)
+		}
+	}
+}
+
+// TestHandleRequest_FileResponseStatusAndHeaders verifies file responses use the
+// endpoint's status and headers.
+func TestHandleRequest_FileResponseStatusAndHeaders(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(filename, []byte("id,name\n1,a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/report": {
+		File:    filename,
+		Status:  http.StatusPartialContent,
+		Headers: map[string]string{"Content-Disposition": `attachment; filename="report.csv"`},
+	}}
+
+	w := httptest.NewRecorder()
+	handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/report", nil), "/v1/report", config, NewErrorSimulator(0.0))
+
+	if w.Code != http.StatusPartialContent {
+		t.Errorf("Expected status 206, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename="report.csv"` {
+		t.Errorf("Expected the endpoint's Content-Disposition, got %q", got)
+	}
+	if got := w.Body.String(); got != "id,name\n1,a\n" {
+		t.Errorf("Expected the file contents, got %q", got)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// harFile is the subset of the HTTP Archive format needed to replay responses.
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+// harEntry is one recorded request and its response.
+type harEntry struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		Status  int         `json:"status"`
+		Headers []harHeader `json:"headers"`
+		Content struct {
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// harHeader is a recorded header.
+type harHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// harSkippedHeaders describe the recorded transfer rather than the response and are not replayed.
+var harSkippedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Encoding":  true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Date":              true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+}
+
+// loadHAR adds the responses recorded in a HAR file to the config, keyed by method and
+// URL path. A JSON object body becomes the response; any other body, such as an array or
+// text, is replayed as-is with its recorded content type. The status and headers are
+// replayed via the endpoint config, starting from any endpoint config of the path.
+// Responses and endpoint settings already configured take precedence, as does the first
+// entry recorded for a key.
+func loadHAR(filename string, config *Config) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("error reading HAR file: %v", err)
+	}
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return fmt.Errorf("error parsing HAR file: %v", err)
+	}
+
+	if config.Endpoints == nil {
+		config.Endpoints = make(map[string]EndpointConfig)
+	}
+	recorded := make(map[string]bool)
+	for _, entry := range har.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return fmt.Errorf("error parsing HAR request URL %q: %v", entry.Request.URL, err)
+		}
+		path := "/" + strings.Trim(u.Path, "/")
+		key := strings.ToUpper(entry.Request.Method) + " " + path
+		if _, exists := config.Responses[key]; exists || recorded[key] {
+			continue
+		}
+		recorded[key] = true
+
+		body := entry.Response.Content.Text
+		if entry.Response.Content.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(body)
+			if err != nil {
+				return fmt.Errorf("error decoding HAR response for %s: %v", key, err)
+			}
+			body = string(decoded)
+		}
+
+		endpoint, exists := config.Endpoints[key]
+		if !exists {
+			endpoint = config.Endpoints[path]
+		}
+		if endpoint.Status == 0 {
+			endpoint.Status = entry.Response.Status
+		}
+		headers := make(map[string]string, len(endpoint.Headers))
+		for name, value := range endpoint.Headers {
+			headers[name] = value
+		}
+		for _, header := range entry.Response.Headers {
+			name := http.CanonicalHeaderKey(header.Name)
+			if name == "Content-Type" && endpoint.ContentType == "" {
+				endpoint.ContentType = header.Value
+			}
+			if _, exists := headers[name]; exists || harSkippedHeaders[name] {
+				continue
+			}
+			headers[name] = header.Value
+		}
+		if len(headers) > 0 {
+			endpoint.Headers = headers
+		}
+
+		if isJSONObject(body) {
+			config.Responses[key] = body
+		} else if endpoint.File == "" && endpoint.BodyBase64 == "" {
+			endpoint.BodyBase64 = base64.StdEncoding.EncodeToString([]byte(body))
+		}
+		config.Endpoints[key] = endpoint
+	}
+	return nil
+}
+
+// isJSONObject reports whether a recorded body is a JSON object, which can be served as
+// a regular response.
+func isJSONObject(body string) bool {
+	var object map[string]interface{}
+	return json.Unmarshal([]byte(body), &object) == nil && object != nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testHAR = `{
+  "log": {
+    "entries": [
+      {
+        "request": {"method": "POST", "url": "https://api.example.com/v1/orders?source=web"},
+        "response": {
+          "status": 201,
+          "headers": [
+            {"name": "content-type", "value": "application/json"},
+            {"name": "x-request-id", "value": "abc123"}
+          ],
+          "content": {"mimeType": "application/json", "text": "{\"id\":42}"}
+        }
+      },
+      {
+        "request": {"method": "GET", "url": "https://api.example.com/v1/test"},
+        "response": {"status": 200, "headers": [], "content": {"text": "{\"message\":\"recorded\"}"}}
+      }
+    ]
+  }
+}`
+
+func TestLoadHAR(t *testing.T) {
+	harFile := filepath.Join(t.TempDir(), "traffic.har")
+	if err := os.WriteFile(harFile, []byte(testHAR), 0644); err != nil {
+		t.Fatalf("Failed to write HAR file: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses["GET /v1/test"] = `{"message":"configured"}`
+	if err := loadHAR(harFile, config); err != nil {
+		t.Fatalf("Expected HAR to load, got error: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "http://example.com/v1/orders", nil)
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/orders", config, NewErrorSimulator(0.0))
+
+	if w.Code != 201 {
+		t.Errorf("Expected recorded status 201, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-Request-Id"); got != "abc123" {
+		t.Errorf("Expected recorded X-Request-Id header, got %q", got)
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != `{"id":42}` {
+		t.Errorf("Expected recorded body, got %s", got)
+	}
+
+	// Configured responses take precedence over recorded ones.
+	if got := config.Responses["GET /v1/test"]; got != `{"message":"configured"}` {
+		t.Errorf("Expected the configured GET /v1/test response to be kept, got %v", got)
+	}
+}
+
+const rawBodiesHAR = `{
+  "log": {
+    "entries": [
+      {
+        "request": {"method": "GET", "url": "https://api.example.com/v1/items"},
+        "response": {
+          "status": 200,
+          "headers": [{"name": "content-type", "value": "application/json"}],
+          "content": {"text": "[{\"id\":1},{\"id\":2}]"}
+        }
+      },
+      {
+        "request": {"method": "GET", "url": "https://api.example.com/v1/health"},
+        "response": {
+          "status": 200,
+          "headers": [{"name": "content-type", "value": "text/plain"}],
+          "content": {"text": "OK"}
+        }
+      }
+    ]
+  }
+}`
+
+// TestLoadHARRawBodies verifies array and text bodies are replayed as recorded, and that
+// path-level endpoint config still applies to the recorded entries.
+func TestLoadHARRawBodies(t *testing.T) {
+	harFile := filepath.Join(t.TempDir(), "traffic.har")
+	if err := os.WriteFile(harFile, []byte(rawBodiesHAR), 0644); err != nil {
+		t.Fatalf("Failed to write HAR file: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/health": {Headers: map[string]string{"X-Service": "health"}},
+	}
+	if err := loadHAR(harFile, config); err != nil {
+		t.Fatalf("Expected HAR to load, got error: %v", err)
+	}
+
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+	}{
+		{"/v1/items", "application/json", `[{"id":1},{"id":2}]`},
+		{"/v1/health", "text/plain", "OK"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://example.com"+tt.path, nil)
+		w := httptest.NewRecorder()
+		handleRequest(w, req, tt.path, config, NewErrorSimulator(0.0))
+
+		if w.Code != 200 {
+			t.Errorf("%s: expected status 200, got %d", tt.path, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: expected Content-Type %s, got %q", tt.path, tt.contentType, got)
+		}
+		if got := w.Body.String(); got != tt.body {
+			t.Errorf("%s: expected recorded body %s, got %s", tt.path, tt.body, got)
+		}
+	}
+
+	if got := config.Endpoints["GET /v1/health"].Headers["X-Service"]; got != "health" {
+		t.Errorf("Expected the path-level X-Service header to be kept, got %q", got)
+	}
+}
+
+func TestLoadHARInvalid(t *testing.T) {
+	harFile := filepath.Join(t.TempDir(), "broken.har")
+	if err := os.WriteFile(harFile, []byte("not json"), 0644); err != nil {
+		t.Fatalf("Failed to write HAR file: %v", err)
+	}
+	if err := loadHAR(harFile, createTestConfig()); err == nil {
+		t.Error("Expected an error for an invalid HAR file")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+)
+
+// headResponseWriter collects a response without sending it, so a HEAD response can
+// declare the length of the body it leaves out.
+type headResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *headResponseWriter) Header() http.Header { return w.header }
+
+func (w *headResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *headResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(data)
+}
+
+// headHandler answers HEAD requests with the status and headers next would send for the
+// same request as a GET, plus a Content-Length of the body it would write. If asGet is
+// false, the request is handled as the HEAD it is.
+func headHandler(next http.HandlerFunc, asGet bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if asGet {
+			r = r.Clone(r.Context())
+			r.Method = http.MethodGet
+		}
+		collected := &headResponseWriter{header: w.Header()}
+		next(collected, r)
+		w.Header().Set("Content-Length", strconv.Itoa(collected.body.Len()))
+		if collected.status == 0 {
+			collected.status = http.StatusOK
+		}
+		w.WriteHeader(collected.status)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// TestHeadContentLength verifies HEAD declares the length of the body GET would send,
+// including GET's override, and writes no body.
+func TestHeadContentLength(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses["GET /v1/items"] = `{"items": ["a", "b", "c"]}`
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/items": {"*": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	get := serve(router, http.MethodGet, "/v1/items")
+	head := serve(router, http.MethodHead, "/v1/items")
+	if head.Code != http.StatusOK {
+		t.Fatalf("Expected HEAD status 200, got %d", head.Code)
+	}
+	if got, want := head.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+		t.Errorf("Expected HEAD Content-Length %s, got %s", want, got)
+	}
+	if head.Header().Get("Content-Type") != get.Header().Get("Content-Type") {
+		t.Errorf("Expected HEAD Content-Type %q, got %q", get.Header().Get("Content-Type"), head.Header().Get("Content-Type"))
+	}
+	if head.Body.Len() != 0 {
+		t.Errorf("Expected no HEAD body, got %q", head.Body.String())
+	}
+}
+
+// TestHeadGetOnlyPath verifies HEAD is answered for a path whose spec declares only GET.
+func TestHeadGetOnlyPath(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/items": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	get := serve(router, http.MethodGet, "/v1/items")
+	head := serve(router, http.MethodHead, "/v1/items")
+	if head.Code != http.StatusOK {
+		t.Fatalf("Expected HEAD status 200, got %d", head.Code)
+	}
+	if got, want := head.Header().Get("Content-Length"), strconv.Itoa(get.Body.Len()); got != want {
+		t.Errorf("Expected HEAD Content-Length %s, got %s", want, got)
+	}
+	if allow := serve(router, http.MethodOptions, "/v1/items").Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("Expected Allow to list HEAD, got %q", allow)
+	}
+}
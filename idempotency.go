@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// idempotencyHeader carries the client-chosen key identifying a repeated request.
+const idempotencyHeader = "Idempotency-Key"
+
+// cachedResponse is a recorded response that can be written again verbatim.
+type cachedResponse struct {
+	status  int
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// write replays the recorded response to w.
+func (c *cachedResponse) write(w http.ResponseWriter) {
+	for key, values := range c.header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(c.status)
+	w.Write(c.body)
+}
+
+// responseCache stores recorded responses by key until they expire.
+// It is safe for concurrent use.
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+}
+
+// newResponseCache creates an empty response cache.
+func newResponseCache() *responseCache {
+	return &responseCache{entries: make(map[string]*cachedResponse)}
+}
+
+// get returns the unexpired response stored under key, if any.
+func (c *responseCache) get(key string, now time.Time) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if now.After(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry, true
+}
+
+// put stores a response under key until expires.
+func (c *responseCache) put(key string, entry *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = entry
+}
+
+// captureWriter passes writes through to the underlying ResponseWriter while
+// recording the status, body, and the headers the handler set. Headers already present
+// when it was created, e.g. from outer middleware, are left out, as are those middleware
+// adds once the status is written, such as Content-Encoding.
+type captureWriter struct {
+	http.ResponseWriter
+	before http.Header
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// newCaptureWriter creates a captureWriter recording what is written to w from now on.
+func newCaptureWriter(w http.ResponseWriter) *captureWriter {
+	return &captureWriter{ResponseWriter: w, before: w.Header().Clone()}
+}
+
+// WriteHeader records the status and the handler's headers before passing it through.
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+		c.header = make(http.Header)
+		for key, values := range c.Header() {
+			if !slices.Equal(values, c.before[key]) {
+				c.header[key] = slices.Clone(values)
+			}
+		}
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the bytes before passing them through.
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+// getIdempotencyKey returns the cache key for a request carrying an Idempotency-Key header,
+// or "" if idempotent replay is disabled or the header is absent. Streaming requests are
+// never replayed.
+func getIdempotencyKey(r *http.Request, method, path string, config *Config) string {
+	key := r.Header.Get(idempotencyHeader)
+	if config.IdempotencyTTL <= 0 || key == "" || isStreaming(r, config) {
+		return ""
+	}
+	return method + " " + strings.TrimRight(path, "/") + " " + key
+}
+
+// serveIdempotent replays the response cached under key if there is one. Otherwise it
+// calls handle with a recording writer and caches the result if it was successful.
+func serveIdempotent(w http.ResponseWriter, key string, config *Config, handle func(http.ResponseWriter)) {
+	state := getRuntimeState(config)
+	cache := state.idempotency
+	now := state.clock.Now()
+	if cached, ok := cache.get(key, now); ok {
+		cached.write(w)
+		return
+	}
+
+	capture := newCaptureWriter(w)
+	handle(capture)
+	if capture.status < 200 || capture.status >= 300 {
+		return
+	}
+	cache.put(key, &cachedResponse{
+		status:  capture.status,
+		header:  capture.header,
+		body:    capture.body.Bytes(),
+		expires: now.Add(config.IdempotencyTTL),
+	})
+}
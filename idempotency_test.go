@@ -0,0 +1,148 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// idempotentRequest sends a POST with the given Idempotency-Key and returns the body.
+func idempotentRequest(config *Config, key string) string {
+	return idempotentRequestTo(config, "/v1/payments", key)
+}
+
+// idempotentRequestTo sends a POST to path with the given Idempotency-Key and returns the body.
+func idempotentRequestTo(config *Config, path, key string) string {
+	req := httptest.NewRequest("POST", "http://example.com"+path, nil)
+	if key != "" {
+		req.Header.Set(idempotencyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	handleRequest(w, req, path, config, NewErrorSimulator(0.0))
+	return w.Body.String()
+}
+
+// createIdempotencyTestConfig returns a config whose payment response has a random id.
+func createIdempotencyTestConfig() *Config {
+	config := createTestConfig()
+	config.Templating = true
+	config.IdempotencyTTL = time.Minute
+	config.Responses["/v1/payments"] = map[string]interface{}{"id": "{{uuid}}"}
+	return config
+}
+
+// TestHandleRequest_IdempotencyKey verifies repeated keys replay byte-identical bodies
+// while different keys get freshly generated ones.
+func TestHandleRequest_IdempotencyKey(t *testing.T) {
+	config := createIdempotencyTestConfig()
+
+	first := idempotentRequest(config, "key-1")
+	second := idempotentRequest(config, "key-1")
+	other := idempotentRequest(config, "key-2")
+
+	if first != second {
+		t.Errorf("Expected identical bodies for the same key, got %q and %q", first, second)
+	}
+	if first == other {
+		t.Errorf("Expected different bodies for different keys, both were %q", first)
+	}
+	if idempotentRequest(config, "") == idempotentRequest(config, "") {
+		t.Error("Expected requests without a key to be generated independently")
+	}
+}
+
+// TestHandleRequest_IdempotencyExpiry verifies replayed responses expire after the TTL.
+func TestHandleRequest_IdempotencyExpiry(t *testing.T) {
+	config := createIdempotencyTestConfig()
+	config.IdempotencyTTL = 50 * time.Millisecond
+
+	first := idempotentRequest(config, "key-1")
+	time.Sleep(100 * time.Millisecond)
+	if again := idempotentRequest(config, "key-1"); again == first {
+		t.Errorf("Expected a regenerated body after the TTL, got %q again", again)
+	}
+}
+
+// TestHandleRequest_IdempotencyErrorsNotReplayed verifies failed responses are not cached.
+func TestHandleRequest_IdempotencyErrorsNotReplayed(t *testing.T) {
+	config := createIdempotencyTestConfig()
+	config.RequestOverrides = true
+
+	req := httptest.NewRequest("POST", "http://example.com/v1/payments?force_error=true", nil)
+	req.Header.Set(idempotencyHeader, "key-1")
+	w := httptest.NewRecorder()
+	handleRequest(w, req, "/v1/payments", config, NewErrorSimulator(0.0))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected forced error, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "http://example.com/v1/payments", nil)
+	req.Header.Set(idempotencyHeader, "key-1")
+	w = httptest.NewRecorder()
+	handleRequest(w, req, "/v1/payments", config, NewErrorSimulator(0.0))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the retry to succeed, got %d", w.Code)
+	}
+}
+
+// TestHandleRequest_IdempotencyTrailingSlash verifies both slash forms of a path share
+// replayed responses.
+func TestHandleRequest_IdempotencyTrailingSlash(t *testing.T) {
+	config := createIdempotencyTestConfig()
+
+	first := idempotentRequestTo(config, "/v1/payments", "key-1")
+	if second := idempotentRequestTo(config, "/v1/payments/", "key-1"); second != first {
+		t.Errorf("Expected /v1/payments/ to replay %q, got %q", first, second)
+	}
+}
+
+// TestHandleRequest_IdempotencyReplayThroughMiddleware verifies a replay gets the headers
+// of its own trip through the middleware rather than those of the original request.
+func TestHandleRequest_IdempotencyReplayThroughMiddleware(t *testing.T) {
+	config := createIdempotencyTestConfig()
+	config.Compression = []string{encodingGzip}
+	config.RateLimit = RateLimitConfig{Requests: 10, Window: time.Minute}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/payments": {"post": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	send := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/payments", nil)
+		req.Header.Set(idempotencyHeader, "key-1")
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send(encodingGzip)
+	if got := first.Header().Get("Content-Encoding"); got != encodingGzip {
+		t.Fatalf("Expected the first response to be gzip-encoded, got %q", got)
+	}
+	reader, err := gzip.NewReader(first.Body)
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Failed to decompress body: %v", err)
+	}
+
+	replay := send("")
+	if got := replay.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Expected the replay not to be labeled %q", got)
+	}
+	if replay.Body.String() != string(body) {
+		t.Errorf("Expected the replay to carry %q, got %q", body, replay.Body.String())
+	}
+	if got := replay.Header().Get("X-RateLimit-Remaining"); got != "8" {
+		t.Errorf("Expected a fresh X-RateLimit-Remaining of 8, got %q", got)
+	}
+	if got := replay.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Expected the handler's Content-Type to be replayed, got %q", got)
+	}
+}
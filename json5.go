@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// json5ToJSON converts the JSON5 conveniences used in hand-written configs into standard
+// JSON: comments are removed, trailing commas dropped, unquoted keys quoted, and
+// single-quoted strings double-quoted. Other JSON5 extensions are not supported.
+func json5ToJSON(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '"' || c == '\'':
+			value, next, err := readJSON5String(data, i)
+			if err != nil {
+				return nil, err
+			}
+			encoded, _ := json.Marshal(value)
+			out.Write(encoded)
+			i = next
+		case c == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*'):
+			next, err := skipJSON5Comment(data, i)
+			if err != nil {
+				return nil, err
+			}
+			i = next
+		case c == ',':
+			next := skipJSON5Space(data, i+1)
+			if next < len(data) && (data[next] == '}' || data[next] == ']') {
+				// Drop the trailing comma.
+				i++
+				continue
+			}
+			out.WriteByte(c)
+			i++
+		case isJSON5IdentifierStart(c):
+			end := i
+			for end < len(data) && isJSON5IdentifierPart(data[end]) {
+				end++
+			}
+			identifier := string(data[i:end])
+			if next := skipJSON5Space(data, end); next < len(data) && data[next] == ':' {
+				encoded, _ := json.Marshal(identifier)
+				out.Write(encoded)
+			} else {
+				out.WriteString(identifier)
+			}
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// readJSON5String reads the string literal starting at data[start], which holds its
+// quote character. It returns the unescaped value and the index after the closing quote.
+func readJSON5String(data []byte, start int) (string, int, error) {
+	quote := data[start]
+	var value strings.Builder
+	for i := start + 1; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == quote:
+			return value.String(), i + 1, nil
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch escaped := data[i]; escaped {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case 'r':
+				value.WriteByte('\r')
+			case 'b':
+				value.WriteByte('\b')
+			case 'f':
+				value.WriteByte('\f')
+			case 'u':
+				var r rune
+				if i+4 >= len(data) {
+					return "", 0, fmt.Errorf("invalid unicode escape in JSON5 string")
+				}
+				if _, err := fmt.Sscanf(string(data[i+1:i+5]), "%04x", &r); err != nil {
+					return "", 0, fmt.Errorf("invalid unicode escape in JSON5 string: %v", err)
+				}
+				value.WriteRune(r)
+				i += 4
+			case '\n':
+				// An escaped newline continues the string on the next line.
+			default:
+				value.WriteByte(escaped)
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string in JSON5 config")
+}
+
+// skipJSON5Comment returns the index after the comment starting at data[start].
+func skipJSON5Comment(data []byte, start int) (int, error) {
+	if data[start+1] == '/' {
+		if end := bytes.IndexByte(data[start:], '\n'); end >= 0 {
+			return start + end, nil
+		}
+		return len(data), nil
+	}
+	end := bytes.Index(data[start+2:], []byte("*/"))
+	if end < 0 {
+		return 0, fmt.Errorf("unterminated comment in JSON5 config")
+	}
+	return start + 2 + end + 2, nil
+}
+
+// skipJSON5Space returns the index of the next character after whitespace and comments.
+func skipJSON5Space(data []byte, i int) int {
+	for i < len(data) {
+		switch {
+		case data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r':
+			i++
+		case data[i] == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*'):
+			next, err := skipJSON5Comment(data, i)
+			if err != nil {
+				return len(data)
+			}
+			i = next
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// isJSON5IdentifierStart reports whether c can start an unquoted key.
+func isJSON5IdentifierStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isJSON5IdentifierPart reports whether c can continue an unquoted key.
+func isJSON5IdentifierPart(c byte) bool {
+	return isJSON5IdentifierStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSON5ToJSON(t *testing.T) {
+	input := `{
+  // line comment
+  key: 'it\'s "quoted"', /* block */
+  "url": "http://example.com/a//b",
+  list: [1, 2, true,],
+}`
+	got, err := json5ToJSON([]byte(input))
+	if err != nil {
+		t.Fatalf("Expected conversion to succeed, got error: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatalf("Expected standard JSON, got %s: %v", got, err)
+	}
+	want := map[string]interface{}{
+		"key":  `it's "quoted"`,
+		"url":  "http://example.com/a//b",
+		"list": []interface{}{float64(1), float64(2), true},
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("Expected %v, got %v", want, decoded)
+	}
+}
+
+func TestJSON5ToJSONUnterminated(t *testing.T) {
+	for _, input := range []string{`{key: "open}`, `{/* open`} {
+		if _, err := json5ToJSON([]byte(input)); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
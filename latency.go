@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Latency distributions supported by LatencyConfig.Distribution.
+const (
+	distributionUniform     = "uniform"
+	distributionNormal      = "normal"
+	distributionExponential = "exponential"
+)
+
+// timeOfDayFormat is the layout of latency schedule times.
+const timeOfDayFormat = "15:04"
+
+// at returns the latency block in effect at now: Low and High come from the first
+// schedule entry covering the time of day, if any.
+func (l LatencyConfig) at(now time.Time) LatencyConfig {
+	minute := now.Hour()*60 + now.Minute()
+	for _, entry := range l.Schedule {
+		from, errFrom := time.Parse(timeOfDayFormat, entry.From)
+		to, errTo := time.Parse(timeOfDayFormat, entry.To)
+		if errFrom != nil || errTo != nil {
+			continue
+		}
+		start, end := from.Hour()*60+from.Minute(), to.Hour()*60+to.Minute()
+		inRange := minute >= start && minute < end
+		if end < start {
+			inRange = minute >= start || minute < end
+		}
+		if inRange {
+			l.Low, l.High = entry.Low, entry.High
+			return l
+		}
+	}
+	return l
+}
+
+// getEndpointLatency returns the latency block for an endpoint, falling back to the global one.
+func getEndpointLatency(method, path string, config *Config) LatencyConfig {
+	if latency := getEndpointConfig(method, path, config).Latency; latency != nil {
+		return *latency
+	}
+	return config.Latency
+}
+
+// sampleLatency draws a latency value in [Low, High] from the configured distribution,
+// or one of the profile's recorded values if it has any.
+//
+//   - uniform: every value in the range is equally likely.
+//   - normal: centered on the midpoint with a standard deviation of a sixth of the range.
+//   - exponential: mostly near Low with a long tail, mean a quarter of the range above Low.
+func sampleLatency(latency LatencyConfig) float64 {
+	if len(latency.samples) > 0 {
+		return latency.samples[rand.Intn(len(latency.samples))]
+	}
+	spread := latency.High - latency.Low
+	var value float64
+	switch latency.Distribution {
+	case distributionNormal:
+		value = latency.Low + spread/2 + rand.NormFloat64()*spread/6
+	case distributionExponential:
+		value = latency.Low + rand.ExpFloat64()*spread/4
+	default:
+		return latency.Low + rand.Float64()*spread
+	}
+	return math.Max(latency.Low, math.Min(latency.High, value))
+}
+
+// latencyDuration converts a latency value in the unit of the latency block to a duration.
+func latencyDuration(latency LatencyConfig, value float64) time.Duration {
+	unit := time.Millisecond
+	if latency.Unit == "s" {
+		unit = time.Second
+	}
+	return time.Duration(value * float64(unit))
+}
+
+// waitLatency blocks for d on clock or until ctx is done, whichever comes first. It
+// returns false if ctx ended the wait early, e.g. because the client disconnected.
+func waitLatency(ctx context.Context, clock Clock, d time.Duration) bool {
+	select {
+	case <-clock.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
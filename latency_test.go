@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// meanLatency averages n samples of the latency resolved for an endpoint.
+func meanLatency(method, path string, config *Config, n int) float64 {
+	latency := getEndpointLatency(method, path, config)
+	total := 0.0
+	for i := 0; i < n; i++ {
+		value := sampleLatency(latency)
+		if value < latency.Low || value > latency.High {
+			return -1
+		}
+		total += value
+	}
+	return total / float64(n)
+}
+
+// TestGetEndpointLatency_Distributions verifies endpoints with different distributions
+// produce distinguishable latency within their ranges.
+func TestGetEndpointLatency_Distributions(t *testing.T) {
+	config := createTestConfig()
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/normal":      {Latency: &LatencyConfig{Low: 0, High: 100, Distribution: "normal"}},
+		"/v1/exponential": {Latency: &LatencyConfig{Low: 0, High: 100, Distribution: "exponential"}},
+	}
+
+	normalMean := meanLatency("GET", "/v1/normal", config, 5000)
+	exponentialMean := meanLatency("GET", "/v1/exponential", config, 5000)
+	if normalMean < 0 || exponentialMean < 0 {
+		t.Fatal("Sampled latency fell outside the configured range")
+	}
+	if normalMean < 45 || normalMean > 55 {
+		t.Errorf("Expected normal mean near 50, got %f", normalMean)
+	}
+	if exponentialMean < 20 || exponentialMean > 30 {
+		t.Errorf("Expected exponential mean near 25, got %f", exponentialMean)
+	}
+}
+
+// TestGetEndpointLatency_Fallback verifies endpoints without a latency block use the global one.
+func TestGetEndpointLatency_Fallback(t *testing.T) {
+	config := createTestConfig()
+	if latency := getEndpointLatency("GET", "/v1/test", config); !reflect.DeepEqual(latency, config.Latency) {
+		t.Errorf("Expected global latency %+v, got %+v", config.Latency, latency)
+	}
+}
+
+// TestHandleRequest_CanceledLatencyWait verifies a canceled request stops waiting promptly
+// and writes no response.
+func TestHandleRequest_CanceledLatencyWait(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 5000, High: 5000}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "http://example.com/v1/test", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected latency wait to abort promptly, took %v", elapsed)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected no body for a canceled request, got %q", w.Body.String())
+	}
+}
+
+// TestWaitLatency verifies an uncanceled wait runs for the full duration.
+func TestWaitLatency(t *testing.T) {
+	start := time.Now()
+	if !waitLatency(context.Background(), realClock{}, 20*time.Millisecond) {
+		t.Fatal("Expected the wait to complete")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected to wait at least 20ms, waited %v", elapsed)
+	}
+}
+
+// TestLatencyAt_Schedule verifies scheduled windows replace the latency range.
+func TestLatencyAt_Schedule(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{
+		Low:  10,
+		High: 10,
+		Schedule: []LatencyScheduleEntry{
+			{From: "09:00", To: "10:00", Low: 500, High: 500},
+			{From: "22:00", To: "02:00", Low: 50, High: 50},
+		},
+	}
+	day := time.Date(2024, time.March, 5, 0, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		at   time.Duration
+		want float64
+	}{
+		{9*time.Hour + 30*time.Minute, 500},
+		{10 * time.Hour, 10},
+		{23 * time.Hour, 50},
+		{time.Hour, 50},
+		{12 * time.Hour, 10},
+	}
+	for _, tt := range tests {
+		now := day.Add(tt.at)
+		if got := sampleLatency(config.Latency.at(now)); got != tt.want {
+			t.Errorf("At %s: expected latency %v, got %v", now.Format(timeOfDayFormat), tt.want, got)
+		}
+	}
+}
+
+func TestSampleLatency_Profile(t *testing.T) {
+	profile := filepath.Join(t.TempDir(), "latency.txt")
+	if err := os.WriteFile(profile, []byte("12\n\n340.5\n7\n"), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Profile: profile}
+	if err := loadLatencyProfile("latency", &config.Latency); err != nil {
+		t.Fatalf("Expected profile to load, got error: %v", err)
+	}
+
+	recorded := map[float64]bool{12: true, 340.5: true, 7: true}
+	seen := make(map[float64]bool)
+	for i := 0; i < 200; i++ {
+		value := sampleLatency(config.Latency)
+		if !recorded[value] {
+			t.Fatalf("Expected a value from the profile, got %v", value)
+		}
+		seen[value] = true
+	}
+	if len(seen) != len(recorded) {
+		t.Errorf("Expected every profile value to be sampled, saw %v", seen)
+	}
+
+	if err := os.WriteFile(profile, []byte("12\nslow\n"), 0644); err != nil {
+		t.Fatalf("Failed to write profile: %v", err)
+	}
+	if err := loadLatencyProfile("latency", &config.Latency); err == nil {
+		t.Error("Expected an error for a non-numeric profile value")
+	}
+}
@@ -2,37 +2,96 @@
 package main
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"sort"
 	"strings"
 
 	"github.com/gorilla/mux"
 )
 
+// defaultPrefix is used when neither the config nor the spec's servers block sets a prefix.
+const defaultPrefix = "v1"
+
+// cliOptions holds the command-line flags.
+type cliOptions struct {
+	// Path to the config file, or "-" for stdin.
+	configFile string
+	// API spec replacing the config's api_spec, or "-" for stdin. Empty keeps api_spec.
+	spec string
+	// Response profile replacing the config's profile. Empty keeps profile.
+	profile string
+	// Port to listen on.
+	port string
+	// Port for an additional HTTPS listener. Empty disables it.
+	tlsPort string
+	// Certificate and key files for the HTTPS listener.
+	tlsCert string
+	tlsKey  string
+	// Only validate the configuration and print the routes.
+	check bool
+	// Only print the JSON Schema of the config file.
+	printSchema bool
+}
+
 // setupFlags initializes and parses command-line flags for server configuration.
-// It returns the paths to the config file and the port number to listen on.
-func setupFlags() (configFile string, port string) {
-	configFilePtr := flag.String("config", "config.yaml", "Path to config file")
-	portPtr := flag.String("port", "8080", "Port to listen on")
+func setupFlags() cliOptions {
+	var options cliOptions
+	flag.StringVar(&options.configFile, "config", "config.yaml", "Path to config file, or - to read it from stdin")
+	flag.StringVar(&options.spec, "spec", "", "API spec replacing the config's api_spec, or - to read it from stdin")
+	flag.StringVar(&options.profile, "profile", "", "Response profile replacing the config's profile, e.g. staging")
+	flag.StringVar(&options.port, "port", "8080", "Port to listen on")
+	flag.StringVar(&options.tlsPort, "tls-port", "", "Port to also serve HTTPS on")
+	flag.StringVar(&options.tlsCert, "tls-cert", "", "TLS certificate file for --tls-port")
+	flag.StringVar(&options.tlsKey, "tls-key", "", "TLS private key file for --tls-port")
+	flag.BoolVar(&options.check, "check", false, "Validate the config and API spec, print the registered routes, and exit")
+	flag.BoolVar(&options.printSchema, "print-config-schema", false, "Print a JSON Schema for the config file and exit")
 	flag.Parse()
-	return *configFilePtr, *portPtr
+	return options
 }
 
 // initializeServer loads and validates the server configuration and API specification.
-// It returns the parsed config and API spec along with any error encountered.
-func initializeServer(configFile string) (*Config, *APISpec, error) {
+// A non-empty specOverride replaces the config's api_spec. It returns the parsed config
+// and API spec along with any error encountered.
+func initializeServer(configFile, specOverride string) (*Config, *APISpec, error) {
+	if configFile == stdinName && specOverride == stdinName {
+		return nil, nil, fmt.Errorf("the config and API spec cannot both be read from stdin")
+	}
 	config, err := loadConfig(configFile)
 	if err != nil {
 		return nil, nil, err
 	}
+	if specOverride != "" {
+		config.APISpec = SpecSources{specOverride}
+	}
 	log.Printf("Loaded config: %+v", config)
 
-	spec, err := loadAPISpec(config.APISpec)
+	spec, err := loadAPISpecs(config.APISpec)
 	if err != nil {
 		return nil, nil, err
 	}
 	log.Printf("Loaded API spec with %d paths", len(spec.Paths))
+	if len(spec.Paths) == 0 {
+		if !config.AllowEmptySpec {
+			return nil, nil, fmt.Errorf("API spec %s defines no paths", config.APISpec)
+		}
+		log.Printf("Warning: API spec %s defines no paths", config.APISpec)
+	}
+
+	if strings.TrimSpace(config.Prefix) == "" {
+		config.Prefix = spec.basePath()
+		if config.Prefix == "" {
+			config.Prefix = defaultPrefix
+		}
+		log.Printf("Using prefix %q", config.Prefix)
+	}
 	return config, spec, nil
 }
 
@@ -44,68 +103,399 @@ func buildFullPath(prefix, path string) string {
 	return "/" + trimmedPrefix + "/" + trimmedPath
 }
 
+// routePaths returns the spec's operations keyed by full path, plus the paths that only
+// appear in the config's responses, so the config alone can drive routing. A config-only
+// "METHOD /path" key registers that method, and a plain "/path" key every method.
+func routePaths(config *Config, spec *APISpec) map[string]map[string]interface{} {
+	paths := make(map[string]map[string]interface{}, len(spec.Paths))
+	for path, methods := range spec.Paths {
+		paths[buildFullPath(config.Prefix, path)] = methods
+	}
+	configOnly := make(map[string]map[string]interface{})
+	for key := range config.Responses {
+		method, path, ok := strings.Cut(key, " ")
+		if !ok {
+			method, path = "*", key
+		}
+		if _, inSpec := paths[path]; inSpec || !strings.HasPrefix(path, "/") {
+			continue
+		}
+		if configOnly[path] == nil {
+			configOnly[path] = make(map[string]interface{})
+		}
+		configOnly[path][method] = map[string]interface{}{}
+	}
+	for path, methods := range configOnly {
+		paths[path] = methods
+	}
+	return paths
+}
+
 // registerMethodHandlers sets up route handlers for all HTTP methods defined in the API spec.
-// It returns a map of valid HTTP methods for the given path.
+// Methods declaring an x-mock error frequency get their own simulator, and x-mock latency
+// applies unless the config sets latency for that endpoint. A JSON response example in the
+// spec becomes the endpoint's default body. A "*" or "any" method key registers every
+// standard method. HEAD is answered as the path's GET would be, without the body, and is
+// registered for every path with a GET. It returns a map of valid HTTP methods for the
+// given path.
 func registerMethodHandlers(router *mux.Router, fullPath string, methods map[string]interface{}, config *Config) map[string]bool {
 	validMethods := make(map[string]bool)
-	simulator := NewErrorSimulator(config.ErrorResponse.Frequency)
-	for method := range methods {
-		httpMethod := strings.ToUpper(method)
+	pathSimulator := NewErrorSimulator(config.ErrorResponse.Frequency)
+	state := getRuntimeState(config)
+	state.registerSimulator(fullPath, pathSimulator)
+	expanded := expandMethods(methods)
+	get, hasGet := expanded[http.MethodGet]
+	if _, hasHead := expanded[http.MethodHead]; hasGet && !hasHead {
+		expanded[http.MethodHead] = get
+	}
+	for httpMethod, operation := range expanded {
 		validMethods[httpMethod] = true
-		router.HandleFunc(fullPath, func(w http.ResponseWriter, r *http.Request) {
+		simulator := pathSimulator
+		hints, err := parseMockHints(operation)
+		if err != nil {
+			log.Printf("Ignoring x-mock for %s %s: %v", httpMethod, fullPath, err)
+		}
+		if hints.ErrorFrequency != nil {
+			simulator = NewErrorSimulator(*hints.ErrorFrequency)
+			state.registerSimulator(httpMethod+" "+fullPath, simulator)
+		}
+		if hints.Latency != nil {
+			if err := applyLatencyHint(httpMethod, fullPath, *hints.Latency, config); err != nil {
+				log.Printf("Ignoring x-mock latency for %s %s: %v", httpMethod, fullPath, err)
+			}
+		}
+		if example, ok, err := parseResponseExample(operation); err != nil {
+			log.Printf("Ignoring response example for %s %s: %v", httpMethod, fullPath, err)
+		} else if ok {
+			state.registerExample(httpMethod+" "+fullPath, example)
+		}
+		if names, err := parseRequiredQueryParams(operation); err != nil {
+			log.Printf("Ignoring parameters for %s %s: %v", httpMethod, fullPath, err)
+		} else if len(names) > 0 {
+			state.registerRequiredQuery(httpMethod+" "+fullPath, names)
+		}
+		if types, err := parsePathParamTypes(operation); err != nil {
+			log.Printf("Ignoring parameters for %s %s: %v", httpMethod, fullPath, err)
+		} else if len(types) > 0 {
+			state.registerPathParamTypes(httpMethod+" "+fullPath, types)
+		}
+		handler := func(w http.ResponseWriter, r *http.Request) {
 			handleRequest(w, r, fullPath, config, simulator)
-		}).Methods(httpMethod)
+		}
+		if httpMethod == http.MethodHead {
+			handler = headHandler(handler, hasGet)
+		}
+		handlePath(router, fullPath, config, handler, httpMethod)
 		log.Printf("Registered endpoint: %s %s", httpMethod, fullPath)
 	}
 	return validMethods
 }
 
+// wildcardMethods are the methods registered for a "*" or "any" method key in the spec.
+var wildcardMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// expandMethods returns the spec operations of a path keyed by uppercase method. A "*"
+// or "any" key stands for every method in wildcardMethods that isn't listed explicitly.
+func expandMethods(methods map[string]interface{}) map[string]interface{} {
+	expanded := make(map[string]interface{}, len(methods))
+	var wildcard interface{}
+	hasWildcard := false
+	for method, operation := range methods {
+		if method == "*" || strings.EqualFold(method, "any") {
+			wildcard, hasWildcard = operation, true
+			continue
+		}
+		expanded[strings.ToUpper(method)] = operation
+	}
+	if hasWildcard {
+		for _, method := range wildcardMethods {
+			if _, ok := expanded[method]; !ok {
+				expanded[method] = wildcard
+			}
+		}
+	}
+	return expanded
+}
+
+// applyLatencyHint records spec-declared latency for an endpoint unless the config
+// already sets latency for it. The endpoint keeps the rest of its path-level config.
+func applyLatencyHint(method, fullPath string, latency LatencyConfig, config *Config) error {
+	endpoint := getEndpointConfig(method, fullPath, config)
+	if endpoint.Latency != nil {
+		return nil
+	}
+	if err := validateLatency("x-mock.latency", latency); err != nil {
+		return err
+	}
+	if err := loadLatencyProfile("x-mock.latency", &latency); err != nil {
+		return err
+	}
+	if config.Endpoints == nil {
+		config.Endpoints = make(map[string]EndpointConfig)
+	}
+	endpoint.Latency = &latency
+	config.Endpoints[method+" "+strings.TrimRight(fullPath, "/")] = endpoint
+	return nil
+}
+
 // registerMethodNotAllowedHandler sets up a handler for requests using unsupported HTTP methods.
-func registerMethodNotAllowedHandler(router *mux.Router, fullPath string) {
-	router.HandleFunc(fullPath, func(w http.ResponseWriter, r *http.Request) {
-		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed")
+// The response lists the supported methods in the Allow header.
+func registerMethodNotAllowedHandler(router *mux.Router, fullPath string, validMethods map[string]bool, config *Config) {
+	allow := strings.Join(sortedMethods(validMethods), ", ")
+	handlePath(router, fullPath, config, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		sendJSONError(w, http.StatusMethodNotAllowed, "Method not allowed", config)
 	})
 }
 
-// registerNotFoundHandler sets up a handler for requests to undefined paths.
-func registerNotFoundHandler(router *mux.Router) {
+// registerOptionsHandler answers OPTIONS on a path whose spec doesn't define it with 204
+// and an Allow header listing the supported methods.
+func registerOptionsHandler(router *mux.Router, fullPath string, validMethods map[string]bool, config *Config) {
+	allow := strings.Join(append(sortedMethods(validMethods), http.MethodOptions), ", ")
+	handlePath(router, fullPath, config, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	}, http.MethodOptions)
+}
+
+// Trailing-slash modes for trailing_slash.
+const (
+	trailingSlashRedirect = "redirect"
+	trailingSlashIgnore   = "ignore"
+	trailingSlashStrict   = "strict"
+)
+
+// trailingSlashMode returns how the router treats /path/ for /path, falling back to
+// strict_slash when trailing_slash isn't set.
+func trailingSlashMode(config *Config) string {
+	if config.TrailingSlash != "" {
+		return config.TrailingSlash
+	}
+	if config.StrictSlash != nil && !*config.StrictSlash {
+		return trailingSlashIgnore
+	}
+	return trailingSlashRedirect
+}
+
+// handlePath registers f for fullPath, limited to methods when any are given. When
+// trailing slashes are ignored the other form is registered too, so it is served without
+// a redirect.
+func handlePath(router *mux.Router, fullPath string, config *Config, f http.HandlerFunc, methods ...string) {
+	paths := []string{fullPath}
+	if trimmed := strings.TrimRight(fullPath, "/"); trimmed != "" && trailingSlashMode(config) == trailingSlashIgnore {
+		paths = []string{trimmed, trimmed + "/"}
+	}
+	for _, path := range paths {
+		route := router.HandleFunc(path, f)
+		if len(methods) > 0 {
+			route.Methods(methods...)
+		}
+	}
+}
+
+// sortedMethods returns the methods of a validMethods map in sorted order.
+func sortedMethods(validMethods map[string]bool) []string {
+	methods := make([]string, 0, len(validMethods))
+	for method := range validMethods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// registerRootHandler serves the configured root_response at the prefix root (e.g. /v1).
+func registerRootHandler(router *mux.Router, config *Config) {
+	rootPath := "/" + strings.Trim(config.Prefix, "/")
+	router.HandleFunc(rootPath, func(w http.ResponseWriter, r *http.Request) {
+		normalResponse(w, decodeResponse(config.RootResponse, config), config)
+	}).Methods(http.MethodGet)
+	log.Printf("Registered root endpoint: GET %s", rootPath)
+}
+
+// registerNotFoundHandler sets up a handler for requests to undefined paths. Requests
+// under the prefix name it in the message, so it is clear which API was hit.
+func registerNotFoundHandler(router *mux.Router, config *Config) {
+	rootPath := "/" + strings.Trim(config.Prefix, "/")
 	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		sendJSONError(w, http.StatusNotFound, "Not found")
+		if rootPath != "/" && (r.URL.Path == rootPath || strings.HasPrefix(r.URL.Path, rootPath+"/")) {
+			sendJSONError(w, http.StatusNotFound, "Not found under "+rootPath, config)
+			return
+		}
+		sendJSONError(w, http.StatusNotFound, "Not found", config)
 	})
 }
 
 // setupRouter configures the HTTP router with all endpoints from the API spec.
 // It returns the configured router ready for use.
 func setupRouter(config *Config, spec *APISpec) *mux.Router {
-	router := mux.NewRouter().StrictSlash(true)
+	router := mux.NewRouter().StrictSlash(trailingSlashMode(config) == trailingSlashRedirect)
 	pathMethods := make(map[string]map[string]bool)
 
-	for path, methods := range spec.Paths {
-		fullPath := buildFullPath(config.Prefix, path)
+	for fullPath, methods := range routePaths(config, spec) {
 		pathMethods[fullPath] = registerMethodHandlers(router, fullPath, methods, config)
-		registerMethodNotAllowedHandler(router, fullPath)
+		if !pathMethods[fullPath][http.MethodOptions] {
+			if config.CORS.Enabled {
+				registerPreflightHandler(router, fullPath, pathMethods[fullPath], config)
+			} else {
+				registerOptionsHandler(router, fullPath, pathMethods[fullPath], config)
+			}
+		}
+		registerMethodNotAllowedHandler(router, fullPath, pathMethods[fullPath], config)
+	}
+
+	registerAdminHandlers(router, config)
+	if config.RootResponse != nil {
+		registerRootHandler(router, config)
+	}
+
+	// The access log is outermost so requests rejected by later middleware are logged too.
+	var accessLog mux.MiddlewareFunc
+	if config.AccessLogFormat != "" {
+		accessLog = accessLogMiddleware(config.AccessLogFormat, accessLogOutput)
+		router.Use(accessLog)
+	}
+	// Denied clients are turned away before they use up concurrency slots or rate limit quota.
+	var ipFilter mux.MiddlewareFunc
+	if len(config.IPAllowlist) > 0 || len(config.IPDenylist) > 0 {
+		ipFilter = ipFilterMiddleware(config)
+		router.Use(ipFilter)
+	}
+	if config.CORS.Enabled {
+		router.Use(corsMiddleware(config))
+	}
+	if config.MaxConcurrent > 0 {
+		router.Use(concurrencyLimitMiddleware(config.MaxConcurrent, config))
+	}
+	if config.RateLimit.Requests > 0 {
+		router.Use(rateLimitMiddleware(config.RateLimit, config))
 	}
 
-	registerNotFoundHandler(router)
+	registerNotFoundHandler(router, config)
+	if config.ServerHeader != "" {
+		// Router middleware only wraps matched routes, so wrap the not-found handler too.
+		serverHeader := serverHeaderMiddleware(config.ServerHeader)
+		router.Use(serverHeader)
+		router.NotFoundHandler = serverHeader(router.NotFoundHandler)
+	}
+	if config.DateSkew != 0 {
+		dateSkew := dateSkewMiddleware(config.DateSkew, config)
+		router.Use(dateSkew)
+		router.NotFoundHandler = dateSkew(router.NotFoundHandler)
+	}
+	if len(config.Compression) > 0 {
+		compression := compressionMiddleware(config.Compression)
+		router.Use(compression)
+		router.NotFoundHandler = compression(router.NotFoundHandler)
+	}
+	if ipFilter != nil {
+		router.NotFoundHandler = ipFilter(router.NotFoundHandler)
+	}
+	if accessLog != nil {
+		router.NotFoundHandler = accessLog(router.NotFoundHandler)
+	}
 	return router
 }
 
+// listRoutes returns the registered "METHOD path" pairs of the router in sorted order.
+func listRoutes(router *mux.Router) ([]string, error) {
+	var routes []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		// Routes without methods are the method-not-allowed fallbacks.
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			routes = append(routes, method+" "+path)
+		}
+		return nil
+	})
+	sort.Strings(routes)
+	return routes, err
+}
+
+// runCheck loads the configuration and API spec (see initializeServer) and builds the
+// router without serving, writing the registered routes to out. It returns any error encountered along the way.
+func runCheck(configFile, specOverride string, out io.Writer) error {
+	config, spec, err := initializeServer(configFile, specOverride)
+	if err != nil {
+		return err
+	}
+	routes, err := listRoutes(setupRouter(config, spec))
+	if err != nil {
+		return err
+	}
+	for _, route := range routes {
+		fmt.Fprintln(out, route)
+	}
+	return nil
+}
+
+// writeConfigSchema writes the JSON Schema of the config file to out.
+func writeConfigSchema(out io.Writer) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(configSchema())
+}
+
 // main initializes and starts the HTTP server with the configured router.
 // It handles command-line flags, loads configuration, and sets up all routes.
 func main() {
-	configFile, port := setupFlags()
+	options := setupFlags()
+	if options.printSchema {
+		if err := writeConfigSchema(os.Stdout); err != nil {
+			log.Fatalf("Failed to print config schema: %v", err)
+		}
+		return
+	}
+	if options.check {
+		if err := runCheck(options.configFile, options.spec, os.Stdout); err != nil {
+			log.Fatalf("Check failed: %v", err)
+		}
+		return
+	}
 
-	config, spec, err := initializeServer(configFile)
+	config, spec, err := initializeServer(options.configFile, options.spec)
 	if err != nil {
 		log.Fatalf("Failed to initialize server: %v", err)
 	}
+	if options.profile != "" {
+		config.Profile = options.profile
+	}
 
 	router := setupRouter(config, spec)
 	log.Printf("Loaded responses: %+v", config.Responses)
 
-	addr := ":" + port
+	addr := ":" + options.port
+	plain, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", addr, err)
+	}
 	log.Printf("Starting server on %s", addr)
-	if err := http.ListenAndServe(addr, router); err != nil {
+
+	var secure net.Listener
+	var tlsConfig *tls.Config
+	if options.tlsPort != "" {
+		tlsConfig, err = loadTLSConfig(options.tlsCert, options.tlsKey, config.TLS)
+		if err != nil {
+			log.Fatalf("Failed to initialize server: %v", err)
+		}
+		tlsAddr := ":" + options.tlsPort
+		secure, err = net.Listen("tcp", tlsAddr)
+		if err != nil {
+			log.Fatalf("Failed to listen on %s: %v", tlsAddr, err)
+		}
+		log.Printf("Starting HTTPS server on %s", tlsAddr)
+	}
+
+	if err := serveListeners(router, plain, secure, tlsConfig); err != nil {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
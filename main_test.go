@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"gopkg.in/yaml.v2"
 )
 
 func TestMainStartup(t *testing.T) {
@@ -23,3 +29,346 @@ func TestMainStartup(t *testing.T) {
 		t.Errorf("Expected 404 for unknown path, got %d", res.StatusCode)
 	}
 }
+
+func TestRunCheck(t *testing.T) {
+	specFile := "test_check_spec.yaml"
+	if err := os.WriteFile(specFile, []byte(validAPISpec), 0644); err != nil {
+		t.Fatalf("Failed to write test API spec: %v", err)
+	}
+	defer os.Remove(specFile)
+
+	configFile := "test_check_config.yaml"
+	config := strings.Replace(validConfig, "spec.yaml", specFile, 1)
+	if err := os.WriteFile(configFile, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	defer os.Remove(configFile)
+
+	var out bytes.Buffer
+	if err := runCheck(configFile, "", &out); err != nil {
+		t.Fatalf("Expected check to pass, got error: %v", err)
+	}
+	if !strings.Contains(out.String(), "GET /v1/test") {
+		t.Errorf("Expected registered route in output, got: %s", out.String())
+	}
+}
+
+func TestRunCheckInvalidConfig(t *testing.T) {
+	var out bytes.Buffer
+	if err := runCheck("non_existent_config.yaml", "", &out); err == nil {
+		t.Fatal("Expected check to fail for a missing config, got nil")
+	}
+}
+
+func TestMethodNotAllowedAllowHeader(t *testing.T) {
+	spec := &APISpec{
+		Paths: map[string]map[string]interface{}{
+			"/items": {"post": map[string]interface{}{}, "get": map[string]interface{}{}},
+		},
+	}
+	router := setupRouter(createTestConfig(), spec)
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/items", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status 405, got %d", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, HEAD, POST" {
+		t.Errorf("Expected Allow header \"GET, HEAD, POST\", got %q", got)
+	}
+}
+
+func TestNotFoundNamesPrefix(t *testing.T) {
+	config := createTestConfig()
+	config.Prefix = "v2"
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	w := serve(router, http.MethodGet, "/v2/missing")
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "/v2") {
+		t.Errorf("Expected a 404 naming /v2, got %d %s", w.Code, w.Body.String())
+	}
+	if w := serve(router, http.MethodGet, "/v20/missing"); strings.Contains(w.Body.String(), "/v2") {
+		t.Errorf("Expected a plain 404 outside the prefix, got %s", w.Body.String())
+	}
+}
+
+func TestOptionsAllowHeader(t *testing.T) {
+	spec := &APISpec{
+		Paths: map[string]map[string]interface{}{
+			"/items": {"post": map[string]interface{}{}, "get": map[string]interface{}{}},
+		},
+	}
+	router := setupRouter(createTestConfig(), spec)
+
+	w := serve(router, http.MethodOptions, "/v1/items")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, HEAD, POST, OPTIONS" {
+		t.Errorf("Expected Allow header \"GET, HEAD, POST, OPTIONS\", got %q", got)
+	}
+}
+
+func TestInitializeServerPrefixFromServers(t *testing.T) {
+	specFile := "test_servers_spec.yaml"
+	spec := "servers:\n  - url: https://example.com/api/v2\n" + validAPISpec
+	if err := os.WriteFile(specFile, []byte(spec), 0644); err != nil {
+		t.Fatalf("Failed to write test API spec: %v", err)
+	}
+	defer os.Remove(specFile)
+
+	configFile := "test_servers_config.yaml"
+	config := strings.Replace(validConfig, "spec.yaml", specFile, 1)
+	config = strings.Replace(config, `prefix: "v1"`, "", 1)
+	if err := os.WriteFile(configFile, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	defer os.Remove(configFile)
+
+	var out bytes.Buffer
+	if err := runCheck(configFile, "", &out); err != nil {
+		t.Fatalf("Expected check to pass, got error: %v", err)
+	}
+	if !strings.Contains(out.String(), "GET /api/v2/test") {
+		t.Errorf("Expected route under the server base path, got: %s", out.String())
+	}
+}
+
+func TestRootResponse(t *testing.T) {
+	config := createTestConfig()
+	config.RootResponse = `{"name":"mock-api","version":"1.0"}`
+	router := setupRouter(config, &APISpec{})
+
+	req := httptest.NewRequest(http.MethodGet, "/v1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 at the prefix root, got %d", w.Code)
+	}
+	if body := strings.TrimSpace(w.Body.String()); body != `{"name":"mock-api","version":"1.0"}` {
+		t.Errorf("Expected configured root body, got %s", body)
+	}
+}
+
+func TestRegisterMethodHandlersMockHints(t *testing.T) {
+	spec := &APISpec{}
+	specYAML := `
+paths:
+  /flaky:
+    get: {}
+    post:
+      x-mock:
+        error_frequency: 1.0
+        latency:
+          low: 1
+          high: 2
+`
+	if err := yaml.Unmarshal([]byte(specYAML), spec); err != nil {
+		t.Fatalf("Failed to parse spec: %v", err)
+	}
+	config := createTestConfig()
+	router := setupRouter(config, spec)
+
+	for _, tt := range []struct {
+		method string
+		want   int
+	}{
+		{http.MethodGet, http.StatusOK},
+		{http.MethodPost, http.StatusInternalServerError},
+	} {
+		req := httptest.NewRequest(tt.method, "/v1/flaky", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s /v1/flaky: expected status %d, got %d", tt.method, tt.want, w.Code)
+		}
+	}
+
+	if latency := getEndpointLatency(http.MethodPost, "/v1/flaky", config); latency.High != 2 {
+		t.Errorf("Expected x-mock latency for POST, got %+v", latency)
+	}
+	if latency := getEndpointLatency(http.MethodGet, "/v1/flaky", config); latency.High != config.Latency.High {
+		t.Errorf("Expected global latency for GET, got %+v", latency)
+	}
+}
+
+func TestApplyLatencyHintKeepsPathConfig(t *testing.T) {
+	config := createTestConfig()
+	config.Endpoints = map[string]EndpointConfig{"/v1/flaky": {Status: http.StatusAccepted, Headers: map[string]string{"X-Mock": "yes"}}}
+
+	if err := applyLatencyHint(http.MethodPost, "/v1/flaky", LatencyConfig{Low: 1, High: 2}, config); err != nil {
+		t.Fatalf("Expected the hint to apply, got error: %v", err)
+	}
+	endpoint := getEndpointConfig(http.MethodPost, "/v1/flaky", config)
+	if endpoint.Latency == nil || endpoint.Latency.High != 2 {
+		t.Errorf("Expected x-mock latency, got %+v", endpoint.Latency)
+	}
+	if endpoint.Status != http.StatusAccepted || endpoint.Headers["X-Mock"] != "yes" {
+		t.Errorf("Expected the path-level status and headers to be kept, got %+v", endpoint)
+	}
+
+	if err := applyLatencyHint(http.MethodGet, "/v1/flaky", LatencyConfig{Low: 1, High: 2, Unit: "h"}, config); err == nil {
+		t.Error("Expected an error for an invalid latency unit")
+	}
+	if endpoint := getEndpointConfig(http.MethodGet, "/v1/flaky", config); endpoint.Latency != nil {
+		t.Errorf("Expected an invalid hint to be ignored, got %+v", endpoint.Latency)
+	}
+}
+
+func TestInitializeServerEmptySpec(t *testing.T) {
+	specFile := "test_empty_spec.yaml"
+	if err := os.WriteFile(specFile, []byte("openapi: 3.0.0\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test API spec: %v", err)
+	}
+	defer os.Remove(specFile)
+
+	configFile := "test_empty_spec_config.yaml"
+	config := strings.Replace(validConfig, "spec.yaml", specFile, 1)
+	if err := os.WriteFile(configFile, []byte(config), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	defer os.Remove(configFile)
+
+	_, _, err := initializeServer(configFile, "")
+	if err == nil || !strings.Contains(err.Error(), "defines no paths") {
+		t.Fatalf("Expected empty spec error, got: %v", err)
+	}
+
+	if err := os.WriteFile(configFile, []byte(config+"allow_empty_spec: true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if _, _, err := initializeServer(configFile, ""); err != nil {
+		t.Errorf("Expected empty spec to be allowed, got: %v", err)
+	}
+}
+
+func TestStrictSlashDisabled(t *testing.T) {
+	spec := &APISpec{
+		Paths: map[string]map[string]interface{}{
+			"/test": {"get": map[string]interface{}{}},
+		},
+	}
+	for _, strict := range []bool{true, false} {
+		config := createTestConfig()
+		config.Latency = LatencyConfig{Low: 0, High: 0}
+		config.StrictSlash = &strict
+		router := setupRouter(config, spec)
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/test/", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		want := http.StatusOK
+		if strict {
+			want = http.StatusMovedPermanently
+		}
+		if w.Code != want {
+			t.Errorf("strict_slash %v: expected status %d, got %d", strict, want, w.Code)
+		}
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses = map[string]interface{}{"/test": map[string]interface{}{"status": "plain"}}
+	tests := []struct {
+		mode      string
+		specPath  string
+		withSlash int
+		without   int
+	}{
+		{trailingSlashRedirect, "/test", http.StatusMovedPermanently, http.StatusOK},
+		{trailingSlashIgnore, "/test", http.StatusOK, http.StatusOK},
+		{trailingSlashIgnore, "/test/", http.StatusOK, http.StatusOK},
+		{trailingSlashStrict, "/test", http.StatusNotFound, http.StatusOK},
+		{trailingSlashStrict, "/test/", http.StatusOK, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		config.TrailingSlash = tt.mode
+		spec := &APISpec{Paths: map[string]map[string]interface{}{tt.specPath: {"get": map[string]interface{}{}}}}
+		router := setupRouter(config, spec)
+
+		withSlash := serve(router, http.MethodGet, "/v1/test/")
+		without := serve(router, http.MethodGet, "/v1/test")
+		if withSlash.Code != tt.withSlash || without.Code != tt.without {
+			t.Errorf("%s %s: expected /v1/test/ %d and /v1/test %d, got %d and %d",
+				tt.mode, tt.specPath, tt.withSlash, tt.without, withSlash.Code, without.Code)
+		}
+		if tt.mode == trailingSlashIgnore && withSlash.Body.String() != without.Body.String() {
+			t.Errorf("%s %s: expected the same response for both forms, got %q and %q",
+				tt.mode, tt.specPath, withSlash.Body.String(), without.Body.String())
+		}
+	}
+}
+
+func TestWildcardMethod(t *testing.T) {
+	spec := &APISpec{
+		Paths: map[string]map[string]interface{}{
+			"/test": {"*": map[string]interface{}{}},
+		},
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	router := setupRouter(config, spec)
+
+	var first string
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+		req := httptest.NewRequest(method, "/v1/test", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", method, w.Code)
+		}
+		if first == "" {
+			first = w.Body.String()
+		} else if w.Body.String() != first {
+			t.Errorf("%s: expected the same body as GET, got %s", method, w.Body.String())
+		}
+	}
+}
+
+func TestConfigOnlyRoutes(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses = map[string]interface{}{
+		"/v1/config-only":        `{"source": "config"}`,
+		"DELETE /v1/delete-only": `{"deleted": true}`,
+	}
+	router := setupRouter(config, &APISpec{})
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		w := serve(router, method, "/v1/config-only")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "config") {
+			t.Errorf("%s: expected the config response, got %d %s", method, w.Code, w.Body.String())
+		}
+	}
+	if w := serve(router, http.MethodDelete, "/v1/delete-only"); w.Code != http.StatusOK {
+		t.Errorf("Expected DELETE on a method-specific key to succeed, got %d", w.Code)
+	}
+	if w := serve(router, http.MethodGet, "/v1/delete-only"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET on a DELETE-only key to be rejected, got %d", w.Code)
+	}
+}
+
+func TestExpandMethodsExplicitWins(t *testing.T) {
+	explicit := map[string]interface{}{"summary": "explicit"}
+	expanded := expandMethods(map[string]interface{}{"any": "wildcard", "get": explicit})
+	if len(expanded) != len(wildcardMethods) {
+		t.Errorf("Expected %d methods, got %v", len(wildcardMethods), expanded)
+	}
+	if !deepEqual(expanded[http.MethodGet], explicit) || expanded[http.MethodPut] != "wildcard" {
+		t.Errorf("Expected explicit methods to win over the wildcard, got %v", expanded)
+	}
+}
+
+func TestInitializeServerBothStdin(t *testing.T) {
+	if _, _, err := initializeServer("-", "-"); err == nil {
+		t.Error("Expected an error when the config and spec both come from stdin")
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+// parseMergeOverride recognizes a response entry written as { merge: true, body: ... },
+// returning the body to merge onto the default response.
+func parseMergeOverride(entry interface{}) (interface{}, bool) {
+	fields, ok := entry.(map[interface{}]interface{})
+	if !ok || len(fields) != 2 {
+		return nil, false
+	}
+	body, hasBody := fields["body"]
+	merge, _ := fields["merge"].(bool)
+	return body, hasBody && merge
+}
+
+// mergeJSON deep-merges override onto base without modifying either. Objects are merged
+// key by key; any other override value, including arrays, replaces the base value.
+func mergeJSON(base, override interface{}) interface{} {
+	baseObject, ok := base.(map[string]interface{})
+	if !ok {
+		return override
+	}
+	overrideObject, ok := override.(map[string]interface{})
+	if !ok {
+		return override
+	}
+	merged := make(map[string]interface{}, len(baseObject)+len(overrideObject))
+	for key, value := range baseObject {
+		merged[key] = value
+	}
+	for key, value := range overrideObject {
+		merged[key] = mergeJSON(baseObject[key], value)
+	}
+	return merged
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestHandleRequest_MergeOverride(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	getRuntimeState(config).registerExample("GET /v1/test", map[interface{}]interface{}{
+		"id":     7,
+		"status": "active",
+		"owner":  map[interface{}]interface{}{"name": "ada", "team": "core"},
+	})
+	config.Responses["/v1/test"] = map[interface{}]interface{}{
+		"merge": true,
+		"body":  `{"status":"suspended","owner":{"team":"billing"}}`,
+	}
+
+	got := requestBody(t, config, "/v1/test", nil)
+	want := map[string]interface{}{
+		"id":     float64(7),
+		"status": "suspended",
+		"owner":  map[string]interface{}{"name": "ada", "team": "billing"},
+	}
+	if !deepEqual(got, want) {
+		t.Errorf("Expected merged body %v, got %v", want, got)
+	}
+}
+
+func TestMergeJSON(t *testing.T) {
+	base := map[string]interface{}{"tags": []interface{}{"a", "b"}, "keep": true}
+	merged := mergeJSON(base, map[string]interface{}{"tags": []interface{}{"c"}})
+	want := map[string]interface{}{"tags": []interface{}{"c"}, "keep": true}
+	if !deepEqual(merged, want) {
+		t.Errorf("Expected arrays to be replaced, got %v", merged)
+	}
+	if len(base["tags"].([]interface{})) != 2 {
+		t.Errorf("Expected the base to be left unchanged, got %v", base)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultLatencyBuckets are the histogram upper bounds, in seconds.
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram is a cumulative histogram in the Prometheus style. It is safe for concurrent use.
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// newHistogram creates an empty histogram with the given bucket upper bounds.
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+// observe records a duration, in seconds.
+func (h *histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// write emits the histogram in the Prometheus text exposition format.
+func (h *histogram) write(w io.Writer, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for i, upper := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, strconv.FormatFloat(upper, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// metrics collects request measurements exposed at /metrics.
+type metrics struct {
+	// Latency chosen by the simulation for each request.
+	simulatedLatency *histogram
+	// Wall-clock time spent handling each request, including the simulated latency.
+	requestDuration *histogram
+}
+
+// newMetrics creates empty metrics.
+func newMetrics() *metrics {
+	return &metrics{
+		simulatedLatency: newHistogram(defaultLatencyBuckets),
+		requestDuration:  newHistogram(defaultLatencyBuckets),
+	}
+}
+
+// handleMetrics writes the collected metrics in the Prometheus text exposition format.
+func handleMetrics(w http.ResponseWriter, config *Config) {
+	m := getRuntimeState(config).metrics
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.simulatedLatency.write(w, "mock_api_simulated_latency_seconds", "Latency chosen by the simulation for each request.")
+	m.requestDuration.write(w, "mock_api_request_duration_seconds", "Time spent handling each request, including simulated latency.")
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHistogramObserve verifies observations land in every bucket at or above them.
+func TestHistogramObserve(t *testing.T) {
+	h := newHistogram([]float64{0.01, 0.1, 1})
+	h.observe(5 * time.Millisecond)
+	h.observe(50 * time.Millisecond)
+	h.observe(2 * time.Second)
+
+	if !deepEqual(h.counts, []uint64{1, 2, 2}) {
+		t.Errorf("Expected cumulative counts [1 2 2], got %v", h.counts)
+	}
+	if h.count != 3 {
+		t.Errorf("Expected count 3, got %d", h.count)
+	}
+}
+
+// TestMetricsEndpoint verifies requests populate the latency histograms at /metrics.
+func TestMetricsEndpoint(t *testing.T) {
+	config, router := createAdminTestRouter()
+	config.ErrorResponse.Frequency = 0
+	config.Latency = LatencyConfig{Low: 20, High: 20}
+	serve(router, http.MethodGet, "/v1/a")
+	serve(router, http.MethodGet, "/v1/b")
+
+	w := serve(router, http.MethodGet, "/metrics")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 from /metrics, got %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, line := range []string{
+		`mock_api_simulated_latency_seconds_bucket{le="0.01"} 0`,
+		`mock_api_simulated_latency_seconds_bucket{le="0.025"} 2`,
+		`mock_api_simulated_latency_seconds_count 2`,
+		`mock_api_request_duration_seconds_bucket{le="+Inf"} 2`,
+		`mock_api_request_duration_seconds_count 2`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in metrics output:\n%s", line, body)
+		}
+	}
+}
+
+// TestRequestDurationCoversLatency verifies the measured handling time is at least the
+// chosen latency of each request.
+func TestRequestDurationCoversLatency(t *testing.T) {
+	config, router := createAdminTestRouter()
+	config.Latency = LatencyConfig{Low: 30, High: 30}
+	serve(router, http.MethodGet, "/v1/a")
+
+	m := getRuntimeState(config).metrics
+	if m.requestDuration.count != 1 || m.simulatedLatency.count != 1 {
+		t.Fatalf("Expected one observation each, got %d and %d", m.requestDuration.count, m.simulatedLatency.count)
+	}
+	if m.requestDuration.sum < m.simulatedLatency.sum {
+		t.Errorf("Expected request duration %vs to be at least the latency %vs", m.requestDuration.sum, m.simulatedLatency.sum)
+	}
+}
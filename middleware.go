@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// concurrencyLimitMiddleware rejects requests with 503 while limit requests are already
+// in flight, simulating a backend with an exhausted connection pool.
+func concurrencyLimitMiddleware(limit int, config *Config) mux.MiddlewareFunc {
+	semaphore := make(chan struct{}, limit)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+				next.ServeHTTP(w, r)
+			default:
+				log.Printf("Rejecting %s %s: %d requests already in flight", r.Method, r.URL.Path, limit)
+				sendJSONError(w, http.StatusServiceUnavailable, "Too many concurrent requests", config)
+			}
+		})
+	}
+}
+
+// rateWindow counts a client's requests in the current rate limit window.
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// rateWindows tracks each client's fixed rate limit window.
+type rateWindows struct {
+	mu      sync.Mutex
+	length  time.Duration
+	windows map[string]*rateWindow
+	swept   time.Time
+}
+
+// hit counts a request from client at now, starting a new window if the last one has
+// ended, and returns the count so far in the window and when it ends.
+func (rw *rateWindows) hit(client string, now time.Time) (int, time.Time) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	// Forget expired windows once per window, so clients that stop sending requests
+	// don't accumulate.
+	if !now.Before(rw.swept.Add(rw.length)) {
+		for ip, window := range rw.windows {
+			if !now.Before(window.start.Add(rw.length)) {
+				delete(rw.windows, ip)
+			}
+		}
+		rw.swept = now
+	}
+	window, ok := rw.windows[client]
+	if !ok || !now.Before(window.start.Add(rw.length)) {
+		window = &rateWindow{start: now}
+		rw.windows[client] = window
+	}
+	window.count++
+	return window.count, window.start.Add(rw.length)
+}
+
+// rateLimitMiddleware allows each client IP limit.Requests requests per fixed window and
+// answers the rest with 429. Every response carries X-RateLimit-Limit,
+// X-RateLimit-Remaining, and X-RateLimit-Reset (Unix seconds) headers.
+func rateLimitMiddleware(limit RateLimitConfig, config *Config) mux.MiddlewareFunc {
+	windows := &rateWindows{length: limit.Window, windows: make(map[string]*rateWindow)}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			now := getRuntimeState(config).clock.Now()
+			client := clientIP(r)
+			count, reset := windows.hit(client, now)
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit.Requests))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(max(0, limit.Requests-count)))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			if count > limit.Requests {
+				log.Printf("Rejecting %s %s from %s: rate limit of %d reached", r.Method, r.URL.Path, client, limit.Requests)
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(reset.Sub(now).Seconds()))))
+				sendJSONError(w, http.StatusTooManyRequests, "Rate limit exceeded", config)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// serverHeaderMiddleware sets the Server header so responses look like they come from
+// the upstream being impersonated.
+func serverHeaderMiddleware(server string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Server", server)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// dateSkewMiddleware sets the Date header to the config's clock shifted by skew, for
+// clients sensitive to server time.
+func dateSkewMiddleware(skew time.Duration, config *Config) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			now := getRuntimeState(config).clock.Now()
+			w.Header().Set("Date", now.Add(skew).UTC().Format(http.TimeFormat))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the host part of the request's remote address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// parseIPRanges parses IP addresses and CIDR ranges, e.g. "10.0.0.0/8" or "::1".
+func parseIPRanges(entries []string) ([]netip.Prefix, error) {
+	ranges := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+			}
+			// Client addresses are unmapped, so IPv4-mapped ranges are too.
+			if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+				prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+			}
+			ranges = append(ranges, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR range", entry)
+		}
+		addr = addr.Unmap()
+		ranges = append(ranges, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return ranges, nil
+}
+
+// inIPRanges reports whether addr falls in any of ranges.
+func inIPRanges(addr netip.Addr, ranges []netip.Prefix) bool {
+	for _, prefix := range ranges {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipFilterMiddleware rejects requests with 403 when the client IP is not in a non-empty
+// allowlist or is in the denylist. Invalid entries are skipped, since loadConfig rejects them.
+func ipFilterMiddleware(config *Config) mux.MiddlewareFunc {
+	allow, _ := parseIPRanges(config.IPAllowlist)
+	deny, _ := parseIPRanges(config.IPDenylist)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addr, err := netip.ParseAddr(clientIP(r))
+			addr = addr.Unmap()
+			if err != nil || (len(allow) > 0 && !inIPRanges(addr, allow)) || inIPRanges(addr, deny) {
+				log.Printf("Rejecting %s %s from %s: client IP not allowed", r.Method, r.URL.Path, r.RemoteAddr)
+				sendJSONError(w, http.StatusForbidden, "Forbidden", config)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrencyLimit verifies the request beyond max_concurrent gets 503 while the
+// others are in flight.
+func TestConcurrencyLimit(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 300, High: 300}
+	config.MaxConcurrent = 2
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	server := httptest.NewServer(setupRouter(config, spec))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	statuses := make([]int, config.MaxConcurrent)
+	for i := range statuses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := http.Get(server.URL + "/v1/test")
+			if err != nil {
+				t.Errorf("Request failed: %v", err)
+				return
+			}
+			res.Body.Close()
+			statuses[i] = res.StatusCode
+		}(i)
+	}
+
+	// Let the first requests reach the handler before sending one more.
+	time.Sleep(100 * time.Millisecond)
+	res, err := http.Get(server.URL + "/v1/test")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 over the limit, got %d", res.StatusCode)
+	}
+
+	wg.Wait()
+	for i, status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("Expected in-flight request %d to succeed, got %d", i, status)
+		}
+	}
+}
+
+// TestServerHeader verifies the configured Server header is set on found and not-found responses.
+func TestServerHeader(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.ServerHeader = "nginx/1.25"
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	for _, path := range []string{"/v1/test", "/v1/missing"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if got := w.Header().Get("Server"); got != "nginx/1.25" {
+			t.Errorf("%s: expected Server header nginx/1.25, got %q", path, got)
+		}
+	}
+}
+
+// TestDateSkew verifies the Date header is offset from the clock by date_skew.
+func TestDateSkew(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.DateSkew = -90 * time.Minute
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	getRuntimeState(config).clock = newFakeClock(now)
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	for _, path := range []string{"/v1/test", "/v1/missing"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		date, err := http.ParseTime(w.Header().Get("Date"))
+		if err != nil {
+			t.Fatalf("%s: expected a valid Date header, got %q", path, w.Header().Get("Date"))
+		}
+		if offset := date.Sub(now); offset != config.DateSkew {
+			t.Errorf("%s: expected Date offset %v, got %v", path, config.DateSkew, offset)
+		}
+	}
+}
+
+// TestIPFilter verifies allowlisted clients are served and others, or denylisted ones, get 403.
+func TestIPFilter(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.IPAllowlist = []string{"10.0.0.0/8", "::1"}
+	config.IPDenylist = []string{"10.0.13.7", "::ffff:10.0.14.0/120"}
+	config.RateLimit = RateLimitConfig{Requests: 100, Window: time.Minute}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	tests := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"10.1.2.3:4567", http.StatusOK},
+		{"[::1]:4567", http.StatusOK},
+		{"192.168.1.1:4567", http.StatusForbidden},
+		{"10.0.13.7:4567", http.StatusForbidden},
+		{"10.0.14.9:4567", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+		req.RemoteAddr = tt.remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.remoteAddr, tt.want, w.Code)
+		}
+		// Denied clients are filtered before the rate limit counts them.
+		if tt.want == http.StatusForbidden && w.Header().Get("X-RateLimit-Limit") != "" {
+			t.Errorf("%s: expected no rate limit headers on a denied request", tt.remoteAddr)
+		}
+	}
+}
+
+func TestParseIPRangesInvalid(t *testing.T) {
+	if _, err := parseIPRanges([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("Expected an error for an invalid CIDR range")
+	}
+	if _, err := parseIPRanges([]string{"localhost"}); err == nil {
+		t.Error("Expected an error for a host name")
+	}
+}
+
+// TestRateLimit verifies the rate limit headers count down, the request over the limit gets
+// 429, and a new window starts after the reset time.
+func TestRateLimit(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.RateLimit = RateLimitConfig{Requests: 2, Window: time.Minute}
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(now)
+	getRuntimeState(config).clock = clock
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	for i, tt := range []struct {
+		status    int
+		remaining string
+	}{
+		{http.StatusOK, "1"},
+		{http.StatusOK, "0"},
+		{http.StatusTooManyRequests, "0"},
+	} {
+		w := serve(router, http.MethodGet, "/v1/test")
+		if w.Code != tt.status {
+			t.Errorf("Request %d: expected status %d, got %d", i+1, tt.status, w.Code)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("Request %d: expected X-RateLimit-Limit 2, got %q", i+1, got)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != tt.remaining {
+			t.Errorf("Request %d: expected X-RateLimit-Remaining %s, got %q", i+1, tt.remaining, got)
+		}
+		reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil || !time.Unix(reset, 0).After(now) {
+			t.Errorf("Request %d: expected X-RateLimit-Reset in the future, got %q", i+1, w.Header().Get("X-RateLimit-Reset"))
+		}
+	}
+
+	clock.Advance(time.Minute)
+	if w := serve(router, http.MethodGet, "/v1/test"); w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Errorf("Expected a fresh window after the reset, got %d with %q remaining", w.Code, w.Header().Get("X-RateLimit-Remaining"))
+	}
+}
+
+// TestRateWindowsSweep verifies windows of clients that stopped sending requests are
+// forgotten once they expire.
+func TestRateWindowsSweep(t *testing.T) {
+	windows := &rateWindows{length: time.Minute, windows: make(map[string]*rateWindow)}
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		windows.hit(fmt.Sprintf("192.0.2.%d", i), now)
+	}
+	windows.hit("198.51.100.1", now.Add(30*time.Second))
+
+	if count, _ := windows.hit("198.51.100.1", now.Add(time.Minute)); count != 2 {
+		t.Errorf("Expected the active client's window to be kept, got count %d", count)
+	}
+	if len(windows.windows) != 1 {
+		t.Errorf("Expected expired windows to be dropped, %d remain", len(windows.windows))
+	}
+}
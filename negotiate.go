@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptable reports whether an Accept header admits mediaType. An empty header accepts
+// anything, as do the */* and type/* ranges; ranges with q=0 are ignored.
+func acceptable(header, mediaType string) bool {
+	if strings.TrimSpace(header) == "" {
+		return true
+	}
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	for _, part := range strings.Split(header, ",") {
+		accepted, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if q, ok := params["q"]; ok {
+			if quality, err := strconv.ParseFloat(q, 64); err == nil && quality <= 0 {
+				continue
+			}
+		}
+		if accepted == "*/*" || accepted == mediaType || accepted == mainType+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// responseType returns the media type an endpoint would respond to the request with.
+func responseType(r *http.Request, endpoint EndpointConfig, config *Config) string {
+	switch {
+	case endpoint.GRPCWeb:
+		return grpcWebContentType
+	case endpoint.File != "" || endpoint.BodyBase64 != "":
+		mediaType, _, _ := mime.ParseMediaType(rawContentType(endpoint))
+		return mediaType
+	case isStreaming(r, config):
+		return "text/event-stream"
+	default:
+		contentType := jsonContentType(config)
+		if endpoint.ContentType != "" {
+			contentType = endpoint.ContentType
+		}
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		return mediaType
+	}
+}
+
+// acceptsContentType reports whether a request's body has one of the accepted media
+// types. Requests without a body or Content-Type, and endpoints accepting anything, pass.
+func acceptsContentType(r *http.Request, accepts []string) bool {
+	header := r.Header.Get("Content-Type")
+	if len(accepts) == 0 || (header == "" && r.ContentLength == 0) {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	for _, accepted := range accepts {
+		if strings.EqualFold(mediaType, accepted) {
+			return true
+		}
+	}
+	return false
+}
+
+// validJSONBody reports whether a JSON request body to an endpoint accepting
+// application/json parses. The body is buffered so matchers can still read it. Other
+// requests, and requests without a body, pass.
+func validJSONBody(r *http.Request, accepts []string) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Body == nil || !strings.EqualFold(mediaType, "application/json") {
+		return true
+	}
+	declared := false
+	for _, accepted := range accepts {
+		declared = declared || strings.EqualFold(accepted, "application/json")
+	}
+	if !declared {
+		return true
+	}
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	return err == nil && (len(data) == 0 || json.Valid(data))
+}
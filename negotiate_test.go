@@ -0,0 +1,116 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptable(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", true},
+		{"application/json", true},
+		{"text/html, application/*;q=0.8", true},
+		{"*/*", true},
+		{"application/xml", false},
+		{"application/json;q=0, text/plain", false},
+	}
+	for _, tt := range tests {
+		if got := acceptable(tt.header, "application/json"); got != tt.want {
+			t.Errorf("acceptable(%q): expected %v, got %v", tt.header, tt.want, got)
+		}
+	}
+}
+
+func TestHandleRequest_StrictAccept(t *testing.T) {
+	for _, strict := range []bool{true, false} {
+		config := createTestConfig()
+		config.Latency = LatencyConfig{Low: 0, High: 0}
+		config.StrictAccept = strict
+
+		req := httptest.NewRequest("GET", "http://example.com/v1/test", nil)
+		req.Header.Set("Accept", "application/xml")
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+
+		want := http.StatusOK
+		if strict {
+			want = http.StatusNotAcceptable
+		}
+		if w.Code != want {
+			t.Errorf("strict_accept %v: expected status %d, got %d", strict, want, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("strict_accept %v: expected a JSON response, got %s", strict, ct)
+		}
+	}
+}
+
+func TestHandleRequest_Accepts(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/test": {Accepts: []string{"application/json"}}}
+
+	tests := []struct {
+		contentType string
+		want        int
+	}{
+		{"application/json; charset=utf-8", http.StatusOK},
+		{"text/plain", http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "http://example.com/v1/test", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", tt.contentType)
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.contentType, tt.want, w.Code)
+		}
+	}
+
+	// Requests without a body are not checked.
+	w := httptest.NewRecorder()
+	handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, NewErrorSimulator(0.0))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected a bodiless GET to pass, got %d", w.Code)
+	}
+}
+
+func TestHandleRequest_AcceptsMalformedJSON(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{"/v1/test": {Accepts: []string{"application/json"}}}
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"name": "widget"}`, http.StatusOK},
+		{`{"name": `, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "http://example.com/v1/test", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+		if w.Code != tt.want {
+			t.Errorf("%q: expected status %d, got %d", tt.body, tt.want, w.Code)
+		}
+	}
+}
+
+func TestValidJSONBodyRestoresBody(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://example.com/v1/test", strings.NewReader(`{"a": 1}`))
+	req.Header.Set("Content-Type", "application/json")
+	if !validJSONBody(req, []string{"application/json"}) {
+		t.Fatal("Expected a valid JSON body to pass")
+	}
+	if data, _ := io.ReadAll(req.Body); string(data) != `{"a": 1}` {
+		t.Errorf("Expected the body to be readable again, got %q", data)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// paddingField is the key of the filler added to padded responses.
+const paddingField = "_padding"
+
+// parsePaddedOverride recognizes a response entry written as { pad_to_bytes: N, body: ... },
+// returning the body and its target size.
+func parsePaddedOverride(entry interface{}) (interface{}, int, bool) {
+	fields, ok := entry.(map[interface{}]interface{})
+	if !ok || len(fields) != 2 {
+		return nil, 0, false
+	}
+	body, hasBody := fields["body"]
+	size, isInt := fields["pad_to_bytes"].(int)
+	return body, size, hasBody && isInt
+}
+
+// padJSON adds a filler field to an object body so that it encodes, with the newline
+// written after JSON responses, to size bytes. Bodies already that large, and bodies that
+// aren't objects, are returned unchanged.
+func padJSON(body interface{}, size int) interface{} {
+	object, ok := body.(map[string]interface{})
+	if !ok {
+		log.Printf("Ignoring pad_to_bytes for a body that isn't a JSON object")
+		return body
+	}
+	padded := make(map[string]interface{}, len(object)+1)
+	for key, value := range object {
+		padded[key] = value
+	}
+	padded[paddingField] = ""
+	encoded, err := json.Marshal(padded)
+	if err != nil {
+		return body
+	}
+	// One byte is left for the trailing newline.
+	missing := size - len(encoded) - 1
+	if missing < 0 {
+		return body
+	}
+	padded[paddingField] = strings.Repeat("x", missing)
+	return padded
+}
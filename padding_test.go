@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleRequest_PadToBytes(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses["/v1/test"] = map[interface{}]interface{}{
+		"pad_to_bytes": 1 << 20,
+		"body":         `{"status":"ok"}`,
+	}
+
+	w := httptest.NewRecorder()
+	handleRequest(w, httptest.NewRequest("GET", "http://example.com/v1/test", nil), "/v1/test", config, NewErrorSimulator(0.0))
+	if size := w.Body.Len(); size != 1<<20 {
+		t.Errorf("Expected a body of %d bytes, got %d", 1<<20, size)
+	}
+	got := requestBody(t, config, "/v1/test", nil)
+	if got["status"] != "ok" {
+		t.Errorf("Expected the configured fields to be kept, got status %v", got["status"])
+	}
+}
+
+func TestPadJSONAlreadyLarger(t *testing.T) {
+	body := map[string]interface{}{"status": "ok"}
+	if padded := padJSON(body, 4); !deepEqual(padded, body) {
+		t.Errorf("Expected a body over the target to be unchanged, got %v", padded)
+	}
+}
@@ -0,0 +1,26 @@
+package main
+
+// parseProfiledOverride recognizes a response entry written as
+// { profiles: { dev: ..., staging: ... }, body: ... }, returning the variant for the
+// active profile, or the base body if the profile has none. A missing base body is
+// returned as nil with hasBody false.
+func parseProfiledOverride(entry interface{}, profile string) (body interface{}, hasBody bool, ok bool) {
+	fields, isMap := entry.(map[interface{}]interface{})
+	if !isMap {
+		return nil, false, false
+	}
+	profiles, hasProfiles := fields["profiles"].(map[interface{}]interface{})
+	if !hasProfiles {
+		return nil, false, false
+	}
+	for key := range fields {
+		if key != "profiles" && key != "body" {
+			return nil, false, false
+		}
+	}
+	if variant, found := profiles[profile]; found && profile != "" {
+		return variant, true, true
+	}
+	body, hasBody = fields["body"]
+	return body, hasBody, true
+}
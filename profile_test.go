@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestResponseProfiles verifies the active profile picks the served variant, falling back
+// to the base body.
+func TestResponseProfiles(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses = map[string]interface{}{
+		"/v1/status": map[interface{}]interface{}{
+			"body": `{"env": "base"}`,
+			"profiles": map[interface{}]interface{}{
+				"dev":     `{"env": "dev"}`,
+				"staging": `{"env": "staging"}`,
+			},
+		},
+	}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/status": {"get": map[string]interface{}{}}}}
+	router := setupRouter(config, spec)
+
+	for _, tt := range []struct{ profile, want string }{
+		{"dev", "dev"},
+		{"staging", "staging"},
+		{"prod", "base"},
+		{"", "base"},
+	} {
+		config.Profile = tt.profile
+		body := serve(router, http.MethodGet, "/v1/status").Body.String()
+		if !strings.Contains(body, `"env":"`+tt.want+`"`) {
+			t.Errorf("Profile %q: expected the %s body, got %s", tt.profile, tt.want, body)
+		}
+	}
+}
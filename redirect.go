@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+	"net/http"
+)
+
+// parseRedirectOverride recognizes a response entry written as
+// { redirect: URL, status: 3xx }, returning the location and status. The status
+// defaults to 302 Found.
+func parseRedirectOverride(entry interface{}) (string, int, bool) {
+	fields, ok := entry.(map[interface{}]interface{})
+	if !ok {
+		return "", 0, false
+	}
+	location, isString := fields["redirect"].(string)
+	if !isString {
+		return "", 0, false
+	}
+	status := http.StatusFound
+	switch len(fields) {
+	case 1:
+	case 2:
+		if status, ok = fields["status"].(int); !ok {
+			return "", 0, false
+		}
+	default:
+		return "", 0, false
+	}
+	return location, status, true
+}
+
+// redirectResponse sends a redirect to location. Statuses outside 3xx fall back to 302.
+func redirectResponse(w http.ResponseWriter, location string, status int) {
+	if status < 300 || status > 399 {
+		log.Printf("Ignoring redirect status %d outside 3xx, using 302", status)
+		status = http.StatusFound
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(status)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectResponse(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses = map[string]interface{}{
+		"GET /v1/authorize": map[interface{}]interface{}{"redirect": "https://example.com/callback"},
+		"GET /v1/moved":     map[interface{}]interface{}{"redirect": "/v1/authorize", "status": 301},
+	}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{
+		"/authorize": {"get": map[string]interface{}{}},
+		"/moved":     {"get": map[string]interface{}{}},
+	}}
+	router := setupRouter(config, spec)
+
+	w := serve(router, http.MethodGet, "/v1/authorize")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/callback" {
+		t.Errorf("Expected 302 to the callback, got %d with Location %q", w.Code, w.Header().Get("Location"))
+	}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.Get(server.URL + "/v1/moved")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMovedPermanently || res.Header.Get("Location") != "/v1/authorize" {
+		t.Errorf("Expected a client not following redirects to see 301 to /v1/authorize, got %d with Location %q",
+			res.StatusCode, res.Header.Get("Location"))
+	}
+}
+
+func TestParseRedirectOverride(t *testing.T) {
+	if _, _, ok := parseRedirectOverride(map[interface{}]interface{}{"redirect": "/x", "body": "{}"}); ok {
+		t.Error("Expected an entry with other fields not to be a redirect")
+	}
+	if _, _, ok := parseRedirectOverride(`{"redirect": "/x"}`); ok {
+		t.Error("Expected a JSON string body not to be a redirect")
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Modes of RuleConditions.QueryMode.
+const (
+	queryModeAny = "any"
+	queryModeAll = "all"
+)
+
+// matchInput is the information about a request that response rules are matched against.
+type matchInput struct {
+	request *http.Request
+	// Number of responses served for the path so far, including this one.
+	count uint64
+	// Request body, read on first use.
+	body func() []byte
+	// Top-level request body fields, parsed on first use.
+	fields func() map[string]string
+}
+
+// newMatchInput prepares a request for matching. The body is only read if a rule needs it.
+func newMatchInput(r *http.Request, count uint64) matchInput {
+	body := sync.OnceValue(func() []byte { return readBody(r) })
+	return matchInput{
+		request: r,
+		count:   count,
+		body:    body,
+		fields:  sync.OnceValue(func() map[string]string { return requestFields(r, body()) }),
+	}
+}
+
+// matchRule returns the first rule whose conditions all match the request.
+func matchRule(input matchInput, rules []ResponseRule) (ResponseRule, bool) {
+	for _, rule := range rules {
+		if rule.When.matches(input) {
+			return rule, true
+		}
+	}
+	return ResponseRule{}, false
+}
+
+// matches reports whether the request satisfies every condition.
+func (c RuleConditions) matches(input matchInput) bool {
+	for name, want := range c.Header {
+		if !strings.EqualFold(strings.TrimSpace(input.request.Header.Get(name)), want) {
+			return false
+		}
+	}
+	for _, name := range c.HeaderPresent {
+		if _, ok := input.request.Header[http.CanonicalHeaderKey(name)]; !ok {
+			return false
+		}
+	}
+	if c.RequestCountLT > 0 && input.count > c.RequestCountLT {
+		return false
+	}
+	if c.BodySizeGT > 0 && int64(len(input.body())) <= c.BodySizeGT {
+		return false
+	}
+	query := input.request.URL.Query()
+	for name, want := range c.Query {
+		if !matchQueryValues(query[name], want, c.QueryMode) {
+			return false
+		}
+	}
+	if len(c.Body) > 0 {
+		fields := input.fields()
+		for name, want := range c.Body {
+			if got, ok := fields[name]; !ok || got != want {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchQueryValues reports whether the values sent for a query parameter satisfy the
+// wanted values: one of them in the "any" mode, every one of them in the "all" mode.
+func matchQueryValues(sent []string, want QueryValues, mode string) bool {
+	for _, value := range want {
+		found := false
+		for _, candidate := range sent {
+			found = found || candidate == value
+		}
+		if found && mode != queryModeAll {
+			return true
+		}
+		if !found && mode == queryModeAll {
+			return false
+		}
+	}
+	return mode == queryModeAll
+}
+
+// readBody reads the request body and restores it so it can be read again.
+func readBody(r *http.Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Error reading request body: %v", err)
+		return nil
+	}
+	return data
+}
+
+// requestFields parses a request body according to the request's Content-Type into a map
+// of top-level field names to values: JSON object members, form fields, or the child
+// elements of an XML document.
+func requestFields(r *http.Request, data []byte) map[string]string {
+	if len(data) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var fields map[string]string
+	var err error
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		fields, err = formFields(data)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		fields, err = xmlFields(data)
+	default:
+		fields, err = jsonFields(data)
+	}
+	if err != nil {
+		log.Printf("Error parsing %s request body: %v", mediaType, err)
+	}
+	return fields
+}
+
+// jsonFields returns the members of a JSON object. Non-string values are kept in their
+// JSON form, e.g. 42, true, or {"a":1}.
+func jsonFields(data []byte) (map[string]string, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string, len(members))
+	for name, raw := range members {
+		var text string
+		if err := json.Unmarshal(raw, &text); err == nil {
+			fields[name] = text
+		} else {
+			fields[name] = string(raw)
+		}
+	}
+	return fields, nil
+}
+
+// formFields returns the first value of each form field.
+func formFields(data []byte) (map[string]string, error) {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string, len(values))
+	for name := range values {
+		fields[name] = values.Get(name)
+	}
+	return fields, nil
+}
+
+// xmlFields returns the trimmed text of each child element of the document's root.
+func xmlFields(data []byte) (map[string]string, error) {
+	fields := make(map[string]string)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	var name string
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return fields, nil
+		}
+		if err != nil {
+			return fields, fmt.Errorf("error decoding XML: %v", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if depth == 2 {
+				name = t.Name.Local
+				text.Reset()
+			}
+		case xml.CharData:
+			if depth == 2 {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				fields[name] = strings.TrimSpace(text.String())
+			}
+			depth--
+		}
+	}
+}
+
+// languagePreference is one language range of an Accept-Language header.
+type languagePreference struct {
+	tag     string
+	quality float64
+}
+
+// parseAcceptLanguage returns the language ranges of an Accept-Language header,
+// lowercased and ordered from most to least preferred.
+func parseAcceptLanguage(header string) []languagePreference {
+	var preferences []languagePreference
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if quality > 0 {
+			preferences = append(preferences, languagePreference{strings.ToLower(tag), quality})
+		}
+	}
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].quality > preferences[j].quality
+	})
+	return preferences
+}
+
+// matchLanguage selects the localized body for the most preferred language in the
+// Accept-Language header. A tag like "fr-CA" also matches a "fr" body.
+func matchLanguage(r *http.Request, localized map[string]interface{}) (interface{}, bool) {
+	if len(localized) == 0 {
+		return nil, false
+	}
+	bodies := make(map[string]interface{}, len(localized))
+	for tag, body := range localized {
+		bodies[strings.ToLower(tag)] = body
+	}
+	for _, preference := range parseAcceptLanguage(r.Header.Get("Accept-Language")) {
+		if body, ok := bodies[preference.tag]; ok {
+			return body, true
+		}
+		primary, _, _ := strings.Cut(preference.tag, "-")
+		if body, ok := bodies[primary]; ok {
+			return body, true
+		}
+	}
+	return nil, false
+}
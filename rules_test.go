@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// requestBody sends a GET to path with the given headers and decodes the JSON response.
+func requestBody(t *testing.T, config *Config, path string, headers map[string]string) map[string]interface{} {
+	t.Helper()
+	req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	handleRequest(w, req, path, config, NewErrorSimulator(0.0))
+
+	var responseData map[string]interface{}
+	if err := json.NewDecoder(w.Result().Body).Decode(&responseData); err != nil {
+		t.Fatalf("Error decoding JSON: %v", err)
+	}
+	return responseData
+}
+
+// TestHandleRequest_HeaderRule verifies a header rule selects its body.
+func TestHandleRequest_HeaderRule(t *testing.T) {
+	config := createTestConfig()
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/test": {Rules: []ResponseRule{{
+			When: RuleConditions{Header: map[string]string{"Accept-Language": "fr"}},
+			Body: `{"greeting":"bonjour"}`,
+		}}},
+	}
+
+	if got := requestBody(t, config, "/v1/test", map[string]string{"Accept-Language": "FR"}); got["greeting"] != "bonjour" {
+		t.Errorf("Expected rule body, got %v", got)
+	}
+	if got := requestBody(t, config, "/v1/test", nil); got["message"] != "override" {
+		t.Errorf("Expected fallback to the response, got %v", got)
+	}
+}
+
+// TestHandleRequest_Localized verifies Accept-Language selects localized bodies with fallback.
+func TestHandleRequest_Localized(t *testing.T) {
+	config := createTestConfig()
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/test": {Localized: map[string]interface{}{
+			"fr": map[string]interface{}{"greeting": "bonjour"},
+			"de": map[string]interface{}{"greeting": "hallo"},
+		}},
+	}
+
+	tests := []struct {
+		header string
+		key    string
+		want   string
+	}{
+		{"fr", "greeting", "bonjour"},
+		{"de-DE,de;q=0.9", "greeting", "hallo"},
+		{"ja;q=1.0, de;q=0.5, fr;q=0.8", "greeting", "bonjour"},
+		{"ja", "message", "override"},
+	}
+	for _, tt := range tests {
+		got := requestBody(t, config, "/v1/test", map[string]string{"Accept-Language": tt.header})
+		if got[tt.key] != tt.want {
+			t.Errorf("Accept-Language %q: expected %s=%s, got %v", tt.header, tt.key, tt.want, got)
+		}
+	}
+}
+
+// TestHandleRequest_RequestCountRule verifies the first N requests get the initializing body.
+func TestHandleRequest_RequestCountRule(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses["/v1/test"] = `{"status":"ready"}`
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/test": {Rules: []ResponseRule{{
+			When: RuleConditions{RequestCountLT: 3},
+			Body: `{"status":"initializing"}`,
+		}}},
+	}
+
+	want := []string{"initializing", "initializing", "initializing", "ready", "ready"}
+	for i, status := range want {
+		if got := requestBody(t, config, "/v1/test", nil); got["status"] != status {
+			t.Errorf("Request %d: expected status %s, got %v", i+1, status, got)
+		}
+	}
+}
+
+// TestHandleRequest_BodyRule verifies body rules match form and JSON fields alike.
+func TestHandleRequest_BodyRule(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/test": {Rules: []ResponseRule{{
+			When: RuleConditions{Body: map[string]string{"plan": "premium"}},
+			Body: `{"tier":"premium"}`,
+		}}},
+	}
+
+	tests := []struct {
+		contentType string
+		body        string
+		want        interface{}
+	}{
+		{"application/x-www-form-urlencoded", "plan=premium&user=a", "premium"},
+		{"application/json", `{"plan":"premium","user":"a"}`, "premium"},
+		{"application/xml; charset=utf-8", "<order><plan> premium </plan></order>", "premium"},
+		{"application/json", `{"plan":"basic"}`, nil},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "http://example.com/v1/test", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/test", config, NewErrorSimulator(0.0))
+
+		var responseData map[string]interface{}
+		if err := json.NewDecoder(w.Result().Body).Decode(&responseData); err != nil {
+			t.Fatalf("Error decoding JSON: %v", err)
+		}
+		if responseData["tier"] != tt.want {
+			t.Errorf("%s %s: expected tier %v, got %v", tt.contentType, tt.body, tt.want, responseData)
+		}
+	}
+}
+
+// TestHandleRequest_HeaderPresentRule verifies a rule can match on header presence alone.
+func TestHandleRequest_HeaderPresentRule(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/test": {Rules: []ResponseRule{{
+			When: RuleConditions{HeaderPresent: []string{"authorization"}},
+			Body: `{"plan":"premium"}`,
+		}}},
+	}
+
+	if got := requestBody(t, config, "/v1/test", map[string]string{"Authorization": "Bearer x"}); got["plan"] != "premium" {
+		t.Errorf("Expected premium body with Authorization, got %v", got)
+	}
+	if got := requestBody(t, config, "/v1/test", nil); got["message"] != "override" {
+		t.Errorf("Expected regular body without Authorization, got %v", got)
+	}
+}
+
+func TestHandleRequest_QueryRuleModes(t *testing.T) {
+	tests := []struct {
+		mode   string
+		target string
+		want   bool
+	}{
+		{"", "/v1/test?tag=a&tag=z", true},
+		{"", "/v1/test?tag=z&tag=y", false},
+		{queryModeAll, "/v1/test?tag=b&tag=a&tag=z", true},
+		{queryModeAll, "/v1/test?tag=a&tag=z", false},
+	}
+	for _, tt := range tests {
+		config := createTestConfig()
+		config.Endpoints = map[string]EndpointConfig{
+			"/v1/test": {Rules: []ResponseRule{{
+				When: RuleConditions{Query: map[string]QueryValues{"tag": {"a", "b"}}, QueryMode: tt.mode},
+				Body: `{"matched":true}`,
+			}}},
+		}
+		w := httptest.NewRecorder()
+		handleRequest(w, httptest.NewRequest("GET", "http://example.com"+tt.target, nil), "/v1/test", config, NewErrorSimulator(0.0))
+		if got := strings.Contains(w.Body.String(), "matched"); got != tt.want {
+			t.Errorf("mode %q, %s: expected match %v, got %v", tt.mode, tt.target, tt.want, got)
+		}
+	}
+}
+
+func TestQueryValuesYAML(t *testing.T) {
+	var conditions RuleConditions
+	if err := yaml.Unmarshal([]byte("query:\n  plan: premium\n  tag: [a, b]\n"), &conditions); err != nil {
+		t.Fatalf("Expected query conditions to parse, got error: %v", err)
+	}
+	want := map[string]QueryValues{"plan": {"premium"}, "tag": {"a", "b"}}
+	if !deepEqual(conditions.Query, want) {
+		t.Errorf("Expected %v, got %v", want, conditions.Query)
+	}
+}
+
+func TestHandleRequest_BodySizeRule(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Endpoints = map[string]EndpointConfig{
+		"/v1/upload": {Rules: []ResponseRule{{
+			When: RuleConditions{BodySizeGT: 1024},
+			Body: `{"status":"accepted"}`,
+		}}},
+	}
+	config.Responses["/v1/upload"] = `{"status":"stored"}`
+
+	for _, tt := range []struct {
+		size int
+		want string
+	}{{10, "stored"}, {1024, "stored"}, {4096, "accepted"}} {
+		req := httptest.NewRequest("POST", "http://example.com/v1/upload", strings.NewReader(strings.Repeat("x", tt.size)))
+		w := httptest.NewRecorder()
+		handleRequest(w, req, "/v1/upload", config, NewErrorSimulator(0.0))
+
+		var responseData map[string]interface{}
+		if err := json.NewDecoder(w.Result().Body).Decode(&responseData); err != nil {
+			t.Fatalf("Error decoding JSON: %v", err)
+		}
+		if responseData["status"] != tt.want {
+			t.Errorf("%d bytes: expected status %s, got %v", tt.size, tt.want, responseData)
+		}
+	}
+}
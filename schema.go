@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaOverrides describes types whose YAML form differs from their Go kind.
+var schemaOverrides = map[reflect.Type]map[string]interface{}{
+	reflect.TypeOf(time.Duration(0)): {"type": "string", "description": "Duration such as \"30s\" or \"5m\""},
+	reflect.TypeOf(time.Time{}):      {"type": "string", "format": "date-time"},
+	reflect.TypeOf(RepeatCount(0)): {"oneOf": []interface{}{
+		map[string]interface{}{"type": "integer"},
+		map[string]interface{}{"const": "infinite"},
+	}},
+	reflect.TypeOf(SpecSources{}): {"oneOf": []interface{}{
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	}},
+	reflect.TypeOf(QueryValues{}): {"oneOf": []interface{}{
+		map[string]interface{}{"type": "string"},
+		map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+	}},
+}
+
+// configSchema returns a JSON Schema for the config file, generated from the yaml tags of
+// Config. Fields tagged schema:"required" are listed as required, and fields tagged
+// schema:"required_unless=a,b" are required unless one of the fields a or b is given.
+func configSchema() map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(Config{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "mock-api config"
+	return schema
+}
+
+// typeSchema returns the JSON Schema of a Go type as decoded from YAML.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if override, ok := schemaOverrides[t]; ok {
+		return override
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		var required []string
+		var alternatives []interface{}
+		addStructFields(t, properties, &required, &alternatives)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		if len(alternatives) > 0 {
+			schema["allOf"] = alternatives
+		}
+		return schema
+	default:
+		// interface{} fields accept any YAML value.
+		return map[string]interface{}{}
+	}
+}
+
+// addStructFields adds the YAML fields of a struct to properties, flattening inline fields.
+// Conditionally required fields add an anyOf of the fields that satisfy them to alternatives.
+func addStructFields(t reflect.Type, properties map[string]interface{}, required *[]string, alternatives *[]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("yaml")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if options == "inline" {
+			addStructFields(field.Type, properties, required, alternatives)
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		properties[name] = typeSchema(field.Type)
+		schemaTag := field.Tag.Get("schema")
+		if schemaTag == "required" {
+			*required = append(*required, name)
+		}
+		if others, ok := strings.CutPrefix(schemaTag, "required_unless="); ok {
+			anyOf := []interface{}{map[string]interface{}{"required": []string{name}}}
+			for _, other := range strings.Split(others, ",") {
+				anyOf = append(anyOf, map[string]interface{}{"required": []string{other}})
+			}
+			*alternatives = append(*alternatives, map[string]interface{}{"anyOf": anyOf})
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteConfigSchema(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeConfigSchema(&out); err != nil {
+		t.Fatalf("Expected schema to be written, got error: %v", err)
+	}
+	var schema struct {
+		Required   []string                          `json:"required"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &schema); err != nil {
+		t.Fatalf("Expected valid JSON, got error: %v", err)
+	}
+
+	required := make(map[string]bool)
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+	for _, name := range []string{"api_spec", "error_response"} {
+		if !required[name] {
+			t.Errorf("Expected %s to be required, got %v", name, schema.Required)
+		}
+	}
+	if required["latency"] {
+		t.Error("Expected latency to be optional, as latency_optional allows")
+	}
+	if schema.Properties["latency"]["type"] != "object" {
+		t.Errorf("Expected latency to be an object, got %v", schema.Properties["latency"])
+	}
+	if schema.Properties["max_concurrent"]["type"] != "integer" {
+		t.Errorf("Expected max_concurrent to be an integer, got %v", schema.Properties["max_concurrent"])
+	}
+	if _, ok := schema.Properties["state"]; ok {
+		t.Error("Expected unexported fields to be omitted")
+	}
+
+	errorResponse := schema.Properties["error_response"]
+	for _, name := range errorResponse["required"].([]interface{}) {
+		if name == "code" {
+			t.Error("Expected error_response.code to be required only without codes or family")
+		}
+	}
+	anyOf := errorResponse["allOf"].([]interface{})[0].(map[string]interface{})["anyOf"]
+	if want := `[{"required":["code"]},{"required":["codes"]},{"required":["family"]}]`; mustJSON(t, anyOf) != want {
+		t.Errorf("Expected error_response to require one of code, codes, or family, got %s", mustJSON(t, anyOf))
+	}
+
+	cors := schema.Properties["cors"]["properties"].(map[string]interface{})
+	if _, ok := cors["allowed_methods"]; !ok {
+		t.Errorf("Expected inline CORS policy fields to be flattened, got %v", cors)
+	}
+}
+
+// mustJSON encodes v for comparison.
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Failed to encode %v: %v", v, err)
+	}
+	return string(data)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// shutdownTimeout bounds how long in-flight requests get when a listener fails.
+const shutdownTimeout = 5 * time.Second
+
+// tlsVersions maps the versions accepted by tls.min_version to their crypto/tls values.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsVersion returns the crypto/tls value of a tls.min_version setting, or zero for the
+// library default if it is empty.
+func tlsVersion(name string) (uint16, error) {
+	if name == "" {
+		return 0, nil
+	}
+	version, ok := tlsVersions[name]
+	if !ok {
+		return 0, fmt.Errorf("invalid tls.min_version %q: must be \"1.0\", \"1.1\", \"1.2\", or \"1.3\"", name)
+	}
+	return version, nil
+}
+
+// loadTLSConfig loads the certificate and key for the HTTPS listener and applies the
+// config's TLS settings.
+func loadTLSConfig(certFile, keyFile string, settings TLSConfig) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("--tls-cert and --tls-key are required with --tls-port")
+	}
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading TLS certificate: %v", err)
+	}
+	minVersion, err := tlsVersion(settings.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: minVersion}, nil
+}
+
+// serveListeners serves handler over plain HTTP on plain and, if secure is non-nil, over
+// HTTPS on secure at the same time. When either listener stops, the other is shut down
+// gracefully. It returns the error that stopped the first listener.
+func serveListeners(handler http.Handler, plain, secure net.Listener, tlsConfig *tls.Config) error {
+	plainServer := &http.Server{Handler: handler}
+	servers := []*http.Server{plainServer}
+	errs := make(chan error, 2)
+	go func() { errs <- plainServer.Serve(plain) }()
+	if secure != nil {
+		secureServer := &http.Server{Handler: handler, TLSConfig: tlsConfig}
+		servers = append(servers, secureServer)
+		// The certificate comes from TLSConfig, so no files are passed.
+		go func() { errs <- secureServer.ServeTLS(secure, "", "") }()
+	}
+
+	err := <-errs
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if shutdownErr := server.Shutdown(ctx); shutdownErr != nil && !errors.Is(shutdownErr, http.ErrServerClosed) {
+			log.Printf("Error shutting down listener: %v", shutdownErr)
+		}
+	}
+	return err
+}
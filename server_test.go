@@ -0,0 +1,147 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// selfSignedTLSConfig creates a TLS config with a certificate for 127.0.0.1 and a pool
+// trusting it.
+func selfSignedTLSConfig(t *testing.T) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mock-api test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, pool
+}
+
+func TestServeListeners(t *testing.T) {
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	tlsConfig, pool := selfSignedTLSConfig(t)
+
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	secure, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- serveListeners(setupRouter(config, spec), plain, secure, tlsConfig) }()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	var bodies []string
+	for _, url := range []string{"http://" + plain.Addr().String(), "https://" + secure.Addr().String()} {
+		res, err := client.Get(url + "/v1/test")
+		if err != nil {
+			t.Fatalf("Request to %s failed: %v", url, err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", url, res.StatusCode)
+		}
+		bodies = append(bodies, string(body))
+	}
+	if bodies[0] != bodies[1] {
+		t.Errorf("Expected both listeners to serve the same body, got %q and %q", bodies[0], bodies[1])
+	}
+
+	// Stopping one listener shuts down the other.
+	plain.Close()
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		t.Fatal("Expected serveListeners to return after a listener closed")
+	}
+	if _, err := client.Get("https://" + secure.Addr().String() + "/v1/test"); err == nil {
+		t.Error("Expected the HTTPS listener to be shut down")
+	}
+}
+
+func TestLoadTLSConfigMinVersion(t *testing.T) {
+	selfSigned, pool := selfSignedTLSConfig(t)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	keyDER, err := x509.MarshalECPrivateKey(selfSigned.Certificates[0].PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: selfSigned.Certificates[0].Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	tlsConfig, err := loadTLSConfig(certFile, keyFile, TLSConfig{MinVersion: "1.3"})
+	if err != nil {
+		t.Fatalf("Expected TLS config to load, got error: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	spec := &APISpec{Paths: map[string]map[string]interface{}{"/test": {"get": map[string]interface{}{}}}}
+	plain, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	secure, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer plain.Close()
+	go serveListeners(setupRouter(config, spec), plain, secure, tlsConfig)
+
+	url := "https://" + secure.Addr().String() + "/v1/test"
+	old := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, MaxVersion: tls.VersionTLS12}}}
+	if res, err := old.Get(url); err == nil {
+		res.Body.Close()
+		t.Error("Expected a TLS 1.2 client to be rejected")
+	}
+	current := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	res, err := current.Get(url)
+	if err != nil {
+		t.Fatalf("Expected a TLS 1.3 client to connect, got error: %v", err)
+	}
+	res.Body.Close()
+
+	if _, err := loadTLSConfig(certFile, keyFile, TLSConfig{MinVersion: "1.4"}); err == nil {
+		t.Error("Expected an error for an unknown TLS version")
+	}
+}
@@ -76,3 +76,11 @@ func (e *ErrorSimulator) GetCurrentErrorRate() float64 {
 	}
 	return float64(atomic.LoadUint64(&e.totalErrors)) / float64(requests)
 }
+
+// Reset zeroes the request and error counters, as if no requests had been made.
+// Each counter is reset atomically, though not both together, so a request racing
+// with Reset may be counted against the fresh totals.
+func (e *ErrorSimulator) Reset() {
+	atomic.StoreUint64(&e.totalRequests, 0)
+	atomic.StoreUint64(&e.totalErrors, 0)
+}
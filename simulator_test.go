@@ -172,3 +172,19 @@ func TestConcurrency(t *testing.T) {
 		t.Errorf("Expected %d total requests, got %d", expectedRequests, sim.totalRequests)
 	}
 }
+
+// TestReset verifies that Reset zeroes the counters.
+func TestReset(t *testing.T) {
+	sim := NewErrorSimulator(1.0)
+	for i := 0; i < 10; i++ {
+		sim.ShouldError()
+	}
+	sim.Reset()
+	if sim.totalRequests != 0 || sim.totalErrors != 0 {
+		t.Errorf("Expected counters to be zero after Reset, got %d requests and %d errors",
+			sim.totalRequests, sim.totalErrors)
+	}
+	if rate := sim.GetCurrentErrorRate(); rate != 0 {
+		t.Errorf("GetCurrentErrorRate() after Reset = %v, want 0", rate)
+	}
+}
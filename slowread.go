@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// slowReadSteps is how many reads slowReadKbps spreads over each second.
+const slowReadSteps = 10
+
+// readSlowly reads the whole request body at slow_read_kbps kilobits per second, then
+// replaces it with the bytes read so later handling sees the full body. It returns false if the client goes
+// away or the body can't be read.
+func readSlowly(r *http.Request, config *Config) bool {
+	if config.SlowReadKbps <= 0 || r.Body == nil {
+		return true
+	}
+	clock := getRuntimeState(config).clock
+	bytesPerSecond := config.SlowReadKbps * 1000 / 8
+	chunk := make([]byte, max(1, int(bytesPerSecond/slowReadSteps)))
+	var body bytes.Buffer
+	for {
+		n, err := r.Body.Read(chunk)
+		body.Write(chunk[:n])
+		if n > 0 && !waitLatency(r.Context(), clock, time.Duration(float64(n)/bytesPerSecond*float64(time.Second))) {
+			return false
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Error reading request body: %v", err)
+			return false
+		}
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(&body)
+	return true
+}
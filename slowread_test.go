@@ -0,0 +1,58 @@
+package main
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadSlowly verifies a body takes as long as slow_read_kbps implies on the clock and
+// is still available afterwards.
+func TestReadSlowly(t *testing.T) {
+	config := createTestConfig()
+	body := strings.Repeat("x", 20000)
+
+	fast := httptest.NewRequest("POST", "/v1/test", strings.NewReader(body))
+	start := time.Now()
+	if !readSlowly(fast, config) {
+		t.Fatal("Expected the body to be read")
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected no delay without slow_read_kbps, took %v", elapsed)
+	}
+
+	// 8 kilobits per second is 1000 bytes per second, so 2000 bytes take 2s.
+	config.SlowReadKbps = 8
+	clock := newFakeClock(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC))
+	getRuntimeState(config).clock = clock
+	body = strings.Repeat("x", 2000)
+	slow := httptest.NewRequest("POST", "/v1/test", strings.NewReader(body))
+	done := make(chan bool)
+	go func() { done <- readSlowly(slow, config) }()
+
+	var elapsed time.Duration
+	for read := false; !read; {
+		select {
+		case ok := <-done:
+			if !ok {
+				t.Fatal("Expected the body to be read")
+			}
+			read = true
+		default:
+			if clock.waiting() == 0 {
+				time.Sleep(time.Millisecond)
+				continue
+			}
+			clock.Advance(100 * time.Millisecond)
+			elapsed += 100 * time.Millisecond
+		}
+	}
+	if elapsed != 2*time.Second {
+		t.Errorf("Expected 2000 bytes at 8 kbps to take 2s, took %v", elapsed)
+	}
+	if data, err := io.ReadAll(slow.Body); err != nil || string(data) != body {
+		t.Errorf("Expected the full body after a slow read, got %d bytes (error %v)", len(data), err)
+	}
+}
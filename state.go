@@ -0,0 +1,180 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+)
+
+// runtimeState holds the mutable state shared by every handler serving a config.
+type runtimeState struct {
+	// Source of the current time for latency, maintenance windows, and caches.
+	clock Clock
+	// Responses replayed for repeated Idempotency-Key requests.
+	idempotency *responseCache
+	// Rendered bodies of cache_ttl responses, keyed by "METHOD path".
+	bodies *bodyCache
+	// Latency and duration histograms exposed at /metrics.
+	metrics *metrics
+	// Number of simulated errors served, used to cycle through a list of error bodies.
+	errorBodyIndex atomic.Uint64
+	// Requests received per path.
+	requestCounts *pathCounters
+	// Responses served per path, matched by request_count_lt rules.
+	responseCounts *pathCounters
+	// Attempts per path, or per path and idempotency key, for fail_first endpoints.
+	attemptCounts *pathCounters
+	// Requests per path for every_nth_error endpoints.
+	nthErrorCounts *pathCounters
+	// Whether maintenance mode was switched on through the admin API.
+	maintenance atomic.Bool
+
+	mu sync.Mutex
+	// Error simulators keyed by the path, or "METHOD path" for per-method simulators.
+	simulators map[string]*ErrorSimulator
+	// Response examples from the spec keyed by "METHOD path", served as the default body.
+	examples map[string]interface{}
+	// Required query parameters from the spec keyed by "METHOD path".
+	requiredQuery map[string][]string
+	// Schema types of path parameters from the spec keyed by "METHOD path", then by name.
+	pathParamTypes map[string]map[string]string
+	// Stream generators registered through RegisterStreamGenerator, keyed by path.
+	generators map[string]StreamGenerator
+}
+
+// newRuntimeState creates empty runtime state.
+func newRuntimeState() *runtimeState {
+	return &runtimeState{
+		clock:          realClock{},
+		idempotency:    newResponseCache(),
+		bodies:         newBodyCache(),
+		metrics:        newMetrics(),
+		simulators:     make(map[string]*ErrorSimulator),
+		examples:       make(map[string]interface{}),
+		generators:     make(map[string]StreamGenerator),
+		requiredQuery:  make(map[string][]string),
+		pathParamTypes: make(map[string]map[string]string),
+		requestCounts:  newPathCounters(),
+		responseCounts: newPathCounters(),
+		attemptCounts:  newPathCounters(),
+		nthErrorCounts: newPathCounters(),
+	}
+}
+
+// getRuntimeState returns the runtime state of a config, creating it on first use.
+// It is safe for concurrent use.
+func getRuntimeState(config *Config) *runtimeState {
+	if state := config.state.Load(); state != nil {
+		return state
+	}
+	config.state.CompareAndSwap(nil, newRuntimeState())
+	return config.state.Load()
+}
+
+// registerSimulator records a simulator so it can be reset through the admin API.
+func (s *runtimeState) registerSimulator(key string, simulator *ErrorSimulator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.simulators[key] = simulator
+}
+
+// resetSimulators resets the simulators serving path, or every simulator if path is
+// empty. It returns how many were reset.
+func (s *runtimeState) resetSimulators(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for key, simulator := range s.simulators {
+		if path == "" || key == path || strings.HasSuffix(key, " "+path) {
+			simulator.Reset()
+			count++
+		}
+	}
+	return count
+}
+
+// registerExample records the spec's response example for an endpoint.
+func (s *runtimeState) registerExample(key string, example interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.examples[key] = example
+}
+
+// example returns the spec's response example for an endpoint, if it has one.
+func (s *runtimeState) example(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	example, ok := s.examples[key]
+	return example, ok
+}
+
+// registerRequiredQuery records the spec's required query parameters for an endpoint.
+func (s *runtimeState) registerRequiredQuery(key string, names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requiredQuery[key] = names
+}
+
+// missingQueryParam returns the first required query parameter of an endpoint that the
+// request doesn't send, or "" if none is missing.
+func (s *runtimeState) missingQueryParam(key string, r *http.Request) string {
+	s.mu.Lock()
+	names := s.requiredQuery[key]
+	s.mu.Unlock()
+	query := r.URL.Query()
+	for _, name := range names {
+		if !query.Has(name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// registerPathParamTypes records the spec's path parameter types for an endpoint.
+func (s *runtimeState) registerPathParamTypes(key string, types map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pathParamTypes[key] = types
+}
+
+// invalidPathParam returns the first path parameter of an endpoint whose value in the
+// request doesn't match its declared type, or "" if all match.
+func (s *runtimeState) invalidPathParam(key string, r *http.Request) string {
+	s.mu.Lock()
+	types := s.pathParamTypes[key]
+	s.mu.Unlock()
+	vars := mux.Vars(r)
+	var invalid []string
+	for name, schemaType := range types {
+		if value, ok := vars[name]; ok && !matchesSchemaType(value, schemaType) {
+			invalid = append(invalid, name)
+		}
+	}
+	if len(invalid) == 0 {
+		return ""
+	}
+	sort.Strings(invalid)
+	return invalid[0]
+}
+
+// matchesSchemaType reports whether a path parameter value parses as a JSON Schema type.
+// Types other than integer, number, and boolean accept any value.
+func matchesSchemaType(value, schemaType string) bool {
+	var err error
+	switch schemaType {
+	case "integer":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "number":
+		_, err = strconv.ParseFloat(value, 64)
+	case "boolean":
+		if value != "true" && value != "false" {
+			return false
+		}
+	}
+	return err == nil
+}
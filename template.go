@@ -0,0 +1,102 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateFuncs are the functions available to response templates.
+var templateFuncs = template.FuncMap{
+	"uuid": newUUID,
+	"randomInt": func(min, max int) int {
+		if max <= min {
+			return min
+		}
+		return min + mathrand.Intn(max-min+1)
+	},
+	"now": func() string {
+		return time.Now().UTC().Format(time.RFC3339)
+	},
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		log.Printf("Error generating UUID: %v", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// templateData is the request data available to response templates, e.g. {{.Body.name}}.
+type templateData struct {
+	// Top-level request body fields. A body that can't be parsed leaves this empty, so
+	// templates referencing it render empty strings.
+	Body map[string]string
+}
+
+// newTemplateData collects the request data for rendering templates.
+func newTemplateData(r *http.Request) templateData {
+	fields := requestFields(r, readBody(r))
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	return templateData{Body: fields}
+}
+
+// renderTemplates returns a copy of data in which every string containing "{{" has been
+// rendered as a text/template. The input is left untouched so overrides can be rendered
+// again for the next request.
+func renderTemplates(data interface{}, vars templateData) interface{} {
+	switch x := data.(type) {
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(x))
+		for k, v := range x {
+			rendered[k] = renderTemplates(v, vars)
+		}
+		return rendered
+	case map[string]string:
+		rendered := make(map[string]string, len(x))
+		for k, v := range x {
+			rendered[k] = renderTemplate(v, vars)
+		}
+		return rendered
+	case []interface{}:
+		rendered := make([]interface{}, len(x))
+		for i, v := range x {
+			rendered[i] = renderTemplates(v, vars)
+		}
+		return rendered
+	case string:
+		return renderTemplate(x, vars)
+	default:
+		return x
+	}
+}
+
+// renderTemplate renders a single string, returning it unchanged if it contains no
+// template actions or fails to render. Missing body fields render as empty strings.
+func renderTemplate(text string, vars templateData) string {
+	if !strings.Contains(text, "{{") {
+		return text
+	}
+	tmpl, err := template.New("response").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		log.Printf("Error parsing response template %q: %v", text, err)
+		return text
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, vars); err != nil {
+		log.Printf("Error rendering response template %q: %v", text, err)
+		return text
+	}
+	return out.String()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestRenderTemplates verifies template functions are expanded without touching the input.
+func TestRenderTemplates(t *testing.T) {
+	input := map[string]interface{}{
+		"id":     "{{uuid}}",
+		"count":  "{{randomInt 5 5}}",
+		"plain":  "no templates here",
+		"nested": []interface{}{map[string]interface{}{"created": "{{now}}"}},
+	}
+
+	rendered := renderTemplates(input, templateData{}).(map[string]interface{})
+
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if id, _ := rendered["id"].(string); !uuidPattern.MatchString(id) {
+		t.Errorf("Expected a UUID, got %v", rendered["id"])
+	}
+	if count, err := strconv.Atoi(rendered["count"].(string)); err != nil || count != 5 {
+		t.Errorf("Expected randomInt 5 5 to render 5, got %v", rendered["count"])
+	}
+	if rendered["plain"] != "no templates here" {
+		t.Errorf("Expected plain string unchanged, got %v", rendered["plain"])
+	}
+	nested := rendered["nested"].([]interface{})[0].(map[string]interface{})
+	if nested["created"] == "{{now}}" {
+		t.Error("Expected nested template to be rendered")
+	}
+	if input["id"] != "{{uuid}}" {
+		t.Errorf("Expected input to be left untouched, got %v", input["id"])
+	}
+}
+
+// TestRenderTemplateInvalid verifies invalid templates are returned unchanged.
+func TestRenderTemplateInvalid(t *testing.T) {
+	if got := renderTemplate("{{unknownFunc}}", templateData{}); got != "{{unknownFunc}}" {
+		t.Errorf("Expected invalid template unchanged, got %q", got)
+	}
+}
+
+// TestRenderTemplateBody verifies body fields render, and render empty for a malformed body.
+func TestRenderTemplateBody(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"name": "Ada"}`, "Hello, Ada!"},
+		{`{"name": `, "Hello, !"},
+		{``, "Hello, !"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/v1/test", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		if got := renderTemplate("Hello, {{.Body.name}}!", newTemplateData(req)); got != tt.want {
+			t.Errorf("Body %q: expected %q, got %q", tt.body, tt.want, got)
+		}
+	}
+}
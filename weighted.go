@@ -0,0 +1,64 @@
+package main
+
+import "math/rand"
+
+// weightedBody is one alternative of a weighted response entry.
+type weightedBody struct {
+	weight float64
+	body   interface{}
+}
+
+// parseWeightedBodies recognizes a response entry written as a list of
+// { weight, body } items. It reports false for any other value, including
+// lists whose items have other keys, so plain JSON arrays are served as-is.
+func parseWeightedBodies(entry interface{}) ([]weightedBody, bool) {
+	items, ok := entry.([]interface{})
+	if !ok || len(items) == 0 {
+		return nil, false
+	}
+	bodies := make([]weightedBody, 0, len(items))
+	for _, item := range items {
+		fields, ok := item.(map[interface{}]interface{})
+		if !ok || len(fields) != 2 {
+			return nil, false
+		}
+		body, hasBody := fields["body"]
+		weight, hasWeight := toWeight(fields["weight"])
+		if !hasBody || !hasWeight {
+			return nil, false
+		}
+		bodies = append(bodies, weightedBody{weight: weight, body: body})
+	}
+	return bodies, true
+}
+
+// toWeight converts a YAML number to a non-negative weight.
+func toWeight(value interface{}) (float64, bool) {
+	var weight float64
+	switch v := value.(type) {
+	case int:
+		weight = float64(v)
+	case float64:
+		weight = v
+	default:
+		return 0, false
+	}
+	return weight, weight >= 0
+}
+
+// pickWeighted returns a body chosen at random in proportion to the weights.
+// If every weight is zero, the first body is returned.
+func pickWeighted(bodies []weightedBody) interface{} {
+	var total float64
+	for _, b := range bodies {
+		total += b.weight
+	}
+	target := rand.Float64() * total
+	for _, b := range bodies {
+		if target < b.weight {
+			return b.body
+		}
+		target -= b.weight
+	}
+	return bodies[0].body
+}
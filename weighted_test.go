@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestHandleRequest_WeightedResponses(t *testing.T) {
+	var entry interface{}
+	spec := `
+- weight: 9
+  body: '{"status":"ok"}'
+- weight: 1
+  body: '{"status":"degraded"}'
+`
+	if err := yaml.Unmarshal([]byte(spec), &entry); err != nil {
+		t.Fatalf("Failed to parse weighted entry: %v", err)
+	}
+	config := createTestConfig()
+	config.Latency = LatencyConfig{Low: 0, High: 0}
+	config.Responses["/v1/test"] = entry
+
+	const requests = 2000
+	counts := make(map[interface{}]int)
+	for i := 0; i < requests; i++ {
+		counts[requestBody(t, config, "/v1/test", nil)["status"]]++
+	}
+	if got := float64(counts["degraded"]) / requests; math.Abs(got-0.1) > 0.03 {
+		t.Errorf("Expected about 10%% degraded responses, got %.3f (%v)", got, counts)
+	}
+	if counts["ok"]+counts["degraded"] != requests {
+		t.Errorf("Unexpected bodies served: %v", counts)
+	}
+}
+
+func TestParseWeightedBodies_PlainList(t *testing.T) {
+	entries := []interface{}{
+		[]interface{}{1, 2, 3},
+		[]interface{}{map[interface{}]interface{}{"weight": 1, "body": "a", "extra": true}},
+		[]interface{}{map[interface{}]interface{}{"weight": "heavy", "body": "a"}},
+		[]interface{}{},
+	}
+	for _, entry := range entries {
+		if _, ok := parseWeightedBodies(entry); ok {
+			t.Errorf("Expected %v not to be treated as weighted", entry)
+		}
+	}
+}